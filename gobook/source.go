@@ -1,10 +1,7 @@
 package gobook
 
 import (
-	"crypto/tls"
-	"errors"
 	"log"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -22,65 +19,36 @@ type Source struct {
 	URL   string `json:"url"`
 }
 
-// 获取重定向之后的 URL
+// 获取重定向之后的 URL，不是跳转时返回空字符串。
+// 和之前一样不验证证书，只处理 http.Client 会自动跳转的状态码
 func GetLocationUrl(rawurl string) (string, error) {
-	trans := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		//Proxy:               b.setting.Proxy,
-		Dial: func(netw, addr string) (net.Conn, error) {
-			conn, err := net.DialTimeout(netw, addr, 30*time.Second)
-			if err != nil {
-				return nil, err
-			}
-			err = conn.SetDeadline(time.Now().Add(30 * time.Second))
-			return conn, err
-		},
-		DisableKeepAlives:   true,
-		MaxIdleConnsPerHost: -1,
-	}
-
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return errors.New("Redirected!")
-		},
-		Transport: trans,
-	}
-
-	req, err := http.NewRequest("GET", rawurl, nil)
+	resp, err := gonet.NewRequest().
+		EnableInsecureTLSSkipVerify().
+		DisableCookie().
+		DisableRedirect().
+		SetTimeout(30*time.Second).
+		Do("GET", rawurl, nil, nil, nil)
 	if err != nil {
 		return "", err
 	}
+	defer resp.Close()
 
-	redir := ""
-
-	resp, err := client.Do(req)
-	if err != nil {
-		if e, ok := err.(*url.Error); ok && e.Err != nil {
-			if !strings.Contains(e.Err.Error(), "dial tcp") {
-				redir = e.URL
-			}
-		} else {
-			return "", err
-		}
-	}
-
-	if resp != nil {
-		defer resp.Body.Close()
-	}
-
-	if redir != "" {
-		return redir, nil
-	}
-
-	switch resp.StatusCode {
+	switch resp.StatusCode() {
 	case http.StatusMovedPermanently,
 		http.StatusFound,
 		http.StatusSeeOther,
-		http.StatusTemporaryRedirect:
-		redir = resp.Header.Get("Location")
+		http.StatusTemporaryRedirect,
+		http.StatusPermanentRedirect:
+	default:
+		return "", nil
+	}
+
+	location := resp.Location()
+	if location == nil {
+		return "", nil
 	}
 
-	return redir, nil
+	return location.String(), nil
 }
 
 func GetOriginalUrlFromBaiduLink(source *Source, wg *sync.WaitGroup) {
@@ -6,6 +6,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"hash"
+	"io"
+	"os"
 )
 
 type GoCrypto struct{}
@@ -62,3 +64,26 @@ func (this *GoCrypto) EncodePassword(rawPwd string, salt string) string {
 	pwd := this.PBKDF2([]byte(rawPwd), []byte(salt), 10000, 50, sha256.New)
 	return hex.EncodeToString(pwd)
 }
+
+// 计算文件的 md5 值，计算完成后文件指针会移回开头
+func (this *GoCrypto) FileMd5(f *os.File) (string, error) {
+	return this.FileHash(f, md5.New)
+}
+
+// 计算文件的 sha256 值，计算完成后文件指针会移回开头
+func (this *GoCrypto) FileSha256(f *os.File) (string, error) {
+	return this.FileHash(f, sha256.New)
+}
+
+func (this *GoCrypto) FileHash(f *os.File, h func() hash.Hash) (string, error) {
+	m := h()
+	if _, err := io.Copy(m, f); err != nil {
+		f.Seek(0, io.SeekStart)
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(m.Sum(nil)), nil
+}
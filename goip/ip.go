@@ -0,0 +1,440 @@
+// goip 是 IP 地址的分类和 CIDR 工具，只依赖标准库。
+// gonet.NetHelper 中有相同的方法，已经使用 gonet 时可以直接使用 gonet 中的
+package goip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+type GoIP struct{}
+
+var Helper = &GoIP{}
+
+var ErrForbiddenAddress = errors.New("goip: forbidden address")
+
+// IPClass 是地址的类别，一个地址可以同时属于多个类别，0 表示公网单播地址
+type IPClass uint
+
+const (
+	IPPrivate       IPClass = 1 << iota // 10/8、172.16/12、192.168/16 和 IPv6 的 ULA
+	IPLoopback                          // 127/8、::1
+	IPLinkLocal                         // 169.254/16、fe80::/10，以及链路本地的组播地址
+	IPCGNAT                             // 运营商级 NAT 使用的 100.64/10
+	IPULA                               // IPv6 唯一本地地址 fc00::/7
+	IPMulticast                         // 224/4、ff00::/8
+	IPDocumentation                     // 文档示例使用的地址，例如 192.0.2/24、2001:db8::/32
+	IPUnspecified                       // 0.0.0.0、::
+	IPReserved                          // 其他特殊用途的地址，例如 0/8、240/4、198.18/15
+)
+
+var ipClassNames = []string{"private", "loopback", "link-local", "cgnat", "ula", "multicast", "documentation", "unspecified", "reserved"}
+
+func (c IPClass) Has(class IPClass) bool {
+	return c&class != 0
+}
+
+func (c IPClass) String() string {
+	if c == 0 {
+		return "public"
+	}
+
+	var names []string
+	for i, name := range ipClassNames {
+		if c&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+var ipClassPrefixes = []struct {
+	prefix netip.Prefix
+	class  IPClass
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), IPReserved},
+	{netip.MustParsePrefix("10.0.0.0/8"), IPPrivate},
+	{netip.MustParsePrefix("100.64.0.0/10"), IPCGNAT},
+	{netip.MustParsePrefix("127.0.0.0/8"), IPLoopback},
+	{netip.MustParsePrefix("169.254.0.0/16"), IPLinkLocal},
+	{netip.MustParsePrefix("172.16.0.0/12"), IPPrivate},
+	{netip.MustParsePrefix("192.0.0.0/24"), IPReserved},
+	{netip.MustParsePrefix("192.0.2.0/24"), IPDocumentation},
+	{netip.MustParsePrefix("192.88.99.0/24"), IPReserved},
+	{netip.MustParsePrefix("192.168.0.0/16"), IPPrivate},
+	{netip.MustParsePrefix("198.18.0.0/15"), IPReserved},
+	{netip.MustParsePrefix("198.51.100.0/24"), IPDocumentation},
+	{netip.MustParsePrefix("203.0.113.0/24"), IPDocumentation},
+	{netip.MustParsePrefix("224.0.0.0/4"), IPMulticast},
+	{netip.MustParsePrefix("224.0.0.0/24"), IPLinkLocal},
+	{netip.MustParsePrefix("240.0.0.0/4"), IPReserved},
+	{netip.MustParsePrefix("::/128"), IPUnspecified},
+	{netip.MustParsePrefix("::1/128"), IPLoopback},
+	{netip.MustParsePrefix("64:ff9b:1::/48"), IPReserved},
+	{netip.MustParsePrefix("100::/64"), IPReserved},
+	{netip.MustParsePrefix("2001::/23"), IPReserved},
+	{netip.MustParsePrefix("2001:db8::/32"), IPDocumentation},
+	{netip.MustParsePrefix("3fff::/20"), IPDocumentation},
+	{netip.MustParsePrefix("fc00::/7"), IPPrivate | IPULA},
+	{netip.MustParsePrefix("fe80::/10"), IPLinkLocal},
+	{netip.MustParsePrefix("ff00::/8"), IPMulticast},
+	{netip.MustParsePrefix("ff02::/16"), IPLinkLocal},
+}
+
+// 地址的类别。IPv4 映射的 IPv6 地址（::ffff:a.b.c.d）按 IPv4 地址判断，
+// NAT64（64:ff9b::/96）和 6to4（2002::/16）地址按其中的 IPv4 地址判断
+func (this *GoIP) ClassifyIP(ip net.IP) IPClass {
+	addr, ok := toAddr(ip)
+	if !ok {
+		return IPReserved
+	}
+
+	return classifyAddr(addr)
+}
+
+func classifyAddr(addr netip.Addr) IPClass {
+	if embedded, ok := embeddedIPv4(addr); ok {
+		return classifyAddr(embedded)
+	}
+
+	var class IPClass
+	if addr == netip.IPv4Unspecified() {
+		class |= IPUnspecified
+	}
+	for _, c := range ipClassPrefixes {
+		if c.prefix.Contains(addr) {
+			class |= c.class
+		}
+	}
+
+	return class
+}
+
+var (
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	sixToFour   = netip.MustParsePrefix("2002::/16")
+)
+
+// NAT64 和 6to4 地址中包含的 IPv4 地址
+func embeddedIPv4(addr netip.Addr) (netip.Addr, bool) {
+	b := addr.As16()
+	switch {
+	case !addr.Is6():
+		return netip.Addr{}, false
+	case nat64Prefix.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}), true
+	case sixToFour.Contains(addr):
+		return netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// 是否是私有地址，包括 IPv4 的 10/8、172.16/12、192.168/16 和 IPv6 的 fc00::/7
+func (this *GoIP) IsPrivateIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPPrivate)
+}
+
+func (this *GoIP) IsLoopbackIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPLoopback)
+}
+
+func (this *GoIP) IsLinkLocalIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPLinkLocal)
+}
+
+func (this *GoIP) IsCGNATIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPCGNAT)
+}
+
+func (this *GoIP) IsULAIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPULA)
+}
+
+func (this *GoIP) IsMulticastIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPMulticast)
+}
+
+func (this *GoIP) IsDocumentationIP(ip net.IP) bool {
+	return this.ClassifyIP(ip).Has(IPDocumentation)
+}
+
+// 是否是公网单播地址。访问用户提供的 URL 时，可以用来防止 SSRF
+func (this *GoIP) IsPublicIP(ip net.IP) bool {
+	return this.ClassifyIP(ip) == 0
+}
+
+// 是否是 IPv4 的私有地址，即 10/8、172.16/12 和 192.168/16
+func (this *GoIP) IsPrivateIPv4(ip net.IP) bool {
+	return ip.To4() != nil && this.IsPrivateIP(ip)
+}
+
+// 本机网卡上的第一个 IPv4 私有地址
+func (this *GoIP) PrivateIPv4() (net.IP, error) {
+	addrs, err := this.InterfaceAddrs("ip4")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range addrs {
+		if a.Class.Has(IPPrivate) && !a.Class.Has(IPLoopback) {
+			return a.IP, nil
+		}
+	}
+	return nil, errors.New("no private ip address")
+}
+
+// 用作 net.Dialer 的 Control，拒绝连接不是公网地址的 IP，返回 ErrForbiddenAddress。
+// 在建立连接时检查解析后的地址，可以防止 DNS 重绑定：
+//
+//	dialer := &net.Dialer{Control: goip.Helper.PublicOnlyControl}
+func (this *GoIP) PublicOnlyControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !this.IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// 解析 CIDR，不带前缀长度的 IP 作为 /32 或者 /128。返回的网络地址已经去掉了主机部分
+func (this *GoIP) ParseCIDR(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, &net.ParseError{Type: "CIDR address", Text: s}
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	_, n, err := net.ParseCIDR(s)
+	return n, err
+}
+
+// 解析多个 CIDR，例如白名单
+func (this *GoIP) ParseCIDRs(list ...string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		n, err := this.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// ip 是否在 nets 中的任意一个网络中
+func (this *GoIP) ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 网络中的第一个和最后一个地址
+func (this *GoIP) CIDRRange(n *net.IPNet) (first, last net.IP) {
+	prefix, ok := toPrefix(n)
+	if !ok {
+		return nil, nil
+	}
+
+	return net.IP(prefix.Addr().AsSlice()), net.IP(lastAddr(prefix).AsSlice())
+}
+
+// 按顺序遍历网络中的每一个地址，f 返回 false 时停止
+func (this *GoIP) EachIP(n *net.IPNet, f func(ip net.IP) bool) {
+	prefix, ok := toPrefix(n)
+	if !ok {
+		return
+	}
+
+	last := lastAddr(prefix)
+	for addr := prefix.Addr(); addr.IsValid(); addr = addr.Next() {
+		if !f(net.IP(addr.AsSlice())) || addr == last {
+			return
+		}
+	}
+}
+
+// 合并重叠和相邻的网络，返回覆盖相同地址的最少的 CIDR 列表，IPv4 在前
+//
+//	MergeCIDRs(10.0.0.0/24, 10.0.1.0/24, 10.0.0.128/25) => 10.0.0.0/23
+func (this *GoIP) MergeCIDRs(nets []*net.IPNet) []*net.IPNet {
+	type ipRange struct {
+		first, last netip.Addr
+	}
+
+	ranges := make([]ipRange, 0, len(nets))
+	for _, n := range nets {
+		if prefix, ok := toPrefix(n); ok {
+			ranges = append(ranges, ipRange{prefix.Addr(), lastAddr(prefix)})
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].first.Less(ranges[j].first)
+	})
+
+	var merged []ipRange
+	for _, r := range ranges {
+		if len(merged) > 0 {
+			prev := &merged[len(merged)-1]
+			next := prev.last.Next()
+			if prev.first.BitLen() == r.first.BitLen() && (!next.IsValid() || !next.Less(r.first)) {
+				if prev.last.Less(r.last) {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+
+	var result []*net.IPNet
+	for _, r := range merged {
+		result = append(result, this.RangeToCIDRs(net.IP(r.first.AsSlice()), net.IP(r.last.AsSlice()))...)
+	}
+
+	return result
+}
+
+// first 到 last 之间（包括 last）的地址转换成最少的 CIDR 列表，两个地址需要是同一类型
+func (this *GoIP) RangeToCIDRs(first, last net.IP) []*net.IPNet {
+	start, ok1 := toAddr(first)
+	end, ok2 := toAddr(last)
+	if !ok1 || !ok2 || start.BitLen() != end.BitLen() || end.Less(start) {
+		return nil
+	}
+
+	var result []*net.IPNet
+	for start.IsValid() && !end.Less(start) {
+		// 从 start 开始、不超过 end 的最大的网络
+		prefix := netip.PrefixFrom(start, start.BitLen())
+		for bits := 0; bits < start.BitLen(); bits++ {
+			p := netip.PrefixFrom(start, bits).Masked()
+			if p.Addr() == start && !end.Less(lastAddr(p)) {
+				prefix = p
+				break
+			}
+		}
+
+		result = append(result, &net.IPNet{
+			IP:   net.IP(prefix.Addr().AsSlice()),
+			Mask: net.CIDRMask(prefix.Bits(), prefix.Addr().BitLen()),
+		})
+		start = lastAddr(prefix).Next()
+	}
+
+	return result
+}
+
+// 一个网卡地址
+type InterfaceAddr struct {
+	Interface string
+	IP        net.IP
+	Network   *net.IPNet
+	Class     IPClass
+}
+
+// 本机所有网卡的地址，family 为 ip4 或 ip6 时只返回对应的地址，为空时返回全部
+func (this *GoIP) InterfaceAddrs(family string) ([]InterfaceAddr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []InterfaceAddr
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+
+			ip := ipnet.IP
+			if ip4 := ip.To4(); ip4 != nil {
+				if family == "ip6" {
+					continue
+				}
+				ip = ip4
+			} else if family == "ip4" {
+				continue
+			}
+
+			result = append(result, InterfaceAddr{
+				Interface: iface.Name,
+				IP:        ip,
+				Network:   &net.IPNet{IP: ip.Mask(ipnet.Mask), Mask: ipnet.Mask},
+				Class:     this.ClassifyIP(ip),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// net.IP 转换成 netip.Addr，IPv4 映射的 IPv6 地址转换成 IPv4 地址
+func toAddr(ip net.IP) (netip.Addr, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+func toPrefix(n *net.IPNet) (netip.Prefix, bool) {
+	if n == nil {
+		return netip.Prefix{}, false
+	}
+	addr, ok := toAddr(n.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	ones, bits := n.Mask.Size()
+	if bits == 0 {
+		return netip.Prefix{}, false
+	}
+	// 4in6 格式的掩码
+	if addr.Is4() && bits == 128 {
+		ones -= 96
+	}
+
+	return netip.PrefixFrom(addr, ones).Masked(), true
+}
+
+// 网络中的最后一个地址
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().As16()
+	offset := 16 - prefix.Addr().BitLen()/8
+	for i := prefix.Bits(); i < prefix.Addr().BitLen(); i++ {
+		b[offset+i/8] |= 1 << uint(7-i%8)
+	}
+
+	addr := netip.AddrFrom16(b)
+	if prefix.Addr().Is4() {
+		return addr.Unmap()
+	}
+	return addr
+}
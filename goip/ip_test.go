@@ -0,0 +1,227 @@
+package goip
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestClassifyIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want IPClass
+	}{
+		{"8.8.8.8", 0},
+		{"2606:4700::1111", 0},
+		{"10.1.2.3", IPPrivate},
+		{"172.16.0.1", IPPrivate},
+		{"172.32.0.1", 0},
+		{"192.168.1.1", IPPrivate},
+		{"100.64.0.1", IPCGNAT},
+		{"127.0.0.1", IPLoopback},
+		{"::1", IPLoopback},
+		{"::ffff:127.0.0.1", IPLoopback},
+		{"169.254.1.1", IPLinkLocal},
+		{"fe80::1", IPLinkLocal},
+		{"fd00::1", IPPrivate | IPULA},
+		{"224.0.0.1", IPMulticast | IPLinkLocal},
+		{"239.1.1.1", IPMulticast},
+		{"ff02::1", IPMulticast | IPLinkLocal},
+		{"192.0.2.1", IPDocumentation},
+		{"2001:db8::1", IPDocumentation},
+		{"0.0.0.0", IPUnspecified | IPReserved},
+		{"::", IPUnspecified},
+		{"240.0.0.1", IPReserved},
+		// NAT64 和 6to4 按其中的 IPv4 地址判断
+		{"64:ff9b::a00:1", IPPrivate},
+		{"2002:c0a8:101::1", IPPrivate},
+		{"2002:808:808::1", 0},
+	}
+	for _, tt := range tests {
+		if got := Helper.ClassifyIP(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("ClassifyIP(%s) = %s, want %s", tt.ip, got, tt.want)
+		}
+	}
+
+	if got := Helper.ClassifyIP(nil); got != IPReserved {
+		t.Errorf("ClassifyIP(nil) = %s", got)
+	}
+	if s := (IPPrivate | IPULA).String(); s != "private|ula" {
+		t.Errorf("String() = %q", s)
+	}
+}
+
+func TestIsPrivateIPv4(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.1", true},
+		{"172.31.255.255", true},
+		{"192.168.0.1", true},
+		{"::ffff:192.168.0.1", true},
+		{"100.64.0.1", false},
+		{"8.8.8.8", false},
+		{"fd00::1", false},
+	}
+	for _, tt := range tests {
+		if got := Helper.IsPrivateIPv4(net.ParseIP(tt.ip)); got != tt.want {
+			t.Errorf("IsPrivateIPv4(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestPublicOnlyControl(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	dialer := &net.Dialer{Timeout: time.Second, Control: Helper.PublicOnlyControl}
+	if _, err := dialer.Dial("tcp", ln.Addr().String()); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("err = %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"10.0.0.1", "10.0.0.1/32"},
+		{"10.0.0.1/24", "10.0.0.0/24"},
+		{"2001:db8::1", "2001:db8::1/128"},
+		{" 2001:db8::1/32 ", "2001:db8::/32"},
+	}
+	for _, tt := range tests {
+		n, err := Helper.ParseCIDR(tt.in)
+		if err != nil || n.String() != tt.want {
+			t.Errorf("ParseCIDR(%q) = %v, %v, want %s", tt.in, n, err, tt.want)
+		}
+	}
+	if _, err := Helper.ParseCIDRs("10.0.0.0/8", "bad"); err == nil {
+		t.Error("ParseCIDRs with an invalid CIDR returned no error")
+	}
+
+	nets, _ := Helper.ParseCIDRs("10.0.0.0/24", "2001:db8::/32")
+	if !Helper.ContainsIP(nets, net.ParseIP("10.0.0.9")) || !Helper.ContainsIP(nets, net.ParseIP("2001:db8::5")) ||
+		Helper.ContainsIP(nets, net.ParseIP("10.0.1.1")) {
+		t.Error("ContainsIP")
+	}
+}
+
+func TestCIDRRange(t *testing.T) {
+	n, _ := Helper.ParseCIDR("10.0.0.0/30")
+	first, last := Helper.CIDRRange(n)
+	if first.String() != "10.0.0.0" || last.String() != "10.0.0.3" {
+		t.Fatalf("CIDRRange = %s, %s", first, last)
+	}
+
+	var ips []string
+	Helper.EachIP(n, func(ip net.IP) bool {
+		ips = append(ips, ip.String())
+		return true
+	})
+	if len(ips) != 4 || ips[0] != "10.0.0.0" || ips[3] != "10.0.0.3" {
+		t.Fatalf("EachIP = %v", ips)
+	}
+
+	count := 0
+	n, _ = Helper.ParseCIDR("2001:db8::/64")
+	Helper.EachIP(n, func(ip net.IP) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Fatalf("EachIP did not stop, count = %d", count)
+	}
+}
+
+func cidrStrings(nets []*net.IPNet) []string {
+	var result []string
+	for _, n := range nets {
+		result = append(result, n.String())
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	tests := []struct {
+		first, last string
+		want        []string
+	}{
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"10.0.0.0", "10.0.1.255", []string{"10.0.0.0/23"}},
+		{"10.0.0.5", "10.0.0.5", []string{"10.0.0.5/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+		{"2001:db8::", "2001:db8::ff", []string{"2001:db8::/120"}},
+		{"10.0.0.6", "10.0.0.1", nil},
+		{"10.0.0.1", "2001:db8::1", nil},
+	}
+	for _, tt := range tests {
+		got := cidrStrings(Helper.RangeToCIDRs(net.ParseIP(tt.first), net.ParseIP(tt.last)))
+		if !equalStrings(got, tt.want) {
+			t.Errorf("RangeToCIDRs(%s, %s) = %v, want %v", tt.first, tt.last, got, tt.want)
+		}
+	}
+}
+
+func TestMergeCIDRs(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"10.0.0.0/24", "10.0.1.0/24", "10.0.0.128/25"}, []string{"10.0.0.0/23"}},
+		{[]string{"10.0.1.0/24", "10.0.0.0/24", "10.0.2.0/24"}, []string{"10.0.0.0/23", "10.0.2.0/24"}},
+		{[]string{"10.0.0.0/8", "10.1.0.0/16"}, []string{"10.0.0.0/8"}},
+		{[]string{"192.168.0.1", "192.168.0.2", "192.168.0.3"}, []string{"192.168.0.1/32", "192.168.0.2/31"}},
+		{[]string{"2001:db8::/33", "10.0.0.0/24", "2001:db8:8000::/33"}, []string{"10.0.0.0/24", "2001:db8::/32"}},
+		{[]string{"255.255.255.0/25", "255.255.255.128/25"}, []string{"255.255.255.0/24"}},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		nets, err := Helper.ParseCIDRs(tt.in...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := cidrStrings(Helper.MergeCIDRs(nets))
+		if !equalStrings(got, tt.want) {
+			t.Errorf("MergeCIDRs(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInterfaceAddrs(t *testing.T) {
+	for _, family := range []string{"ip4", "ip6"} {
+		addrs, err := Helper.InterfaceAddrs(family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range addrs {
+			if is4 := a.IP.To4() != nil; is4 != (family == "ip4") {
+				t.Errorf("InterfaceAddrs(%s) returned %s", family, a.IP)
+			}
+			if !a.Network.Contains(a.IP) {
+				t.Errorf("%s is not in %s", a.IP, a.Network)
+			}
+			if a.Class != Helper.ClassifyIP(a.IP) {
+				t.Errorf("%s: Class = %s", a.IP, a.Class)
+			}
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package gonet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RequestBuilder 构建一个请求，使用 Request 的设置发送：
+//
+//	resp, err := req.NewBuilder().Query(url.Values{"page": {"2"}}).GET("http://example.com/list")
+//	resp, err := req.NewBuilder().JSONBody(user).PUT("http://example.com/users/1")
+//	resp, err := req.NewBuilder().
+//		Field("title", "avatar").
+//		FilePath("file", "avatar.png").
+//		POST("http://example.com/upload")
+//
+// 请求体可以重复生成时（表单、JSON、FilePath 等），重试和跳转时会重新发送请求体
+type RequestBuilder struct {
+	request *Request
+	ctx     context.Context
+	query   url.Values
+	header  http.Header
+	body    func() (io.Reader, string, error) // 请求体和 Content-Type
+	parts   []*multipartPart
+}
+
+type multipartPart struct {
+	name        string
+	fileName    string
+	contentType string
+	open        func() (io.ReadCloser, error)
+	rewindable  bool
+}
+
+func (this *Request) NewBuilder() *RequestBuilder {
+	return &RequestBuilder{
+		request: this,
+		query:   url.Values{},
+		header:  http.Header{},
+	}
+}
+
+func (this *RequestBuilder) Context(ctx context.Context) *RequestBuilder {
+	this.ctx = ctx
+	return this
+}
+
+// 添加查询参数，和 URL 中已有的参数合并
+func (this *RequestBuilder) Query(values url.Values) *RequestBuilder {
+	for key, vs := range values {
+		for _, v := range vs {
+			this.query.Add(key, v)
+		}
+	}
+	return this
+}
+
+// 设置本次请求的请求头，覆盖 Request 中的同名请求头
+func (this *RequestBuilder) Header(key, value string) *RequestBuilder {
+	this.header.Set(key, value)
+	return this
+}
+
+// application/x-www-form-urlencoded 格式的请求体
+func (this *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	encoded := values.Encode()
+	return this.setBody(func() (io.Reader, string, error) {
+		return strings.NewReader(encoded), "application/x-www-form-urlencoded", nil
+	})
+}
+
+// 把 v 序列化为 JSON 作为请求体
+func (this *RequestBuilder) JSONBody(v interface{}) *RequestBuilder {
+	return this.setBody(func() (io.Reader, string, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(data), "application/json; charset=UTF-8", nil
+	})
+}
+
+// 原样发送 r，contentType 为空时不设置 Content-Type
+func (this *RequestBuilder) Body(r io.Reader, contentType string) *RequestBuilder {
+	return this.setBody(func() (io.Reader, string, error) {
+		return r, contentType, nil
+	})
+}
+
+// multipart/form-data 中的普通字段
+func (this *RequestBuilder) Field(name, value string) *RequestBuilder {
+	this.parts = append(this.parts, &multipartPart{
+		name: name,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(value)), nil
+		},
+		rewindable: true,
+	})
+	return this
+}
+
+// multipart/form-data 中的文件，发送时才从 r 中读取。
+// r 只能读取一次，所以包含这种文件的请求不会重试。contentType 为空时根据 fileName 的扩展名判断
+func (this *RequestBuilder) File(name, fileName, contentType string, r io.Reader) *RequestBuilder {
+	this.parts = append(this.parts, &multipartPart{
+		name:        name,
+		fileName:    fileName,
+		contentType: contentType,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	})
+	return this
+}
+
+// multipart/form-data 中的文件，内容在 data 中
+func (this *RequestBuilder) FileBytes(name, fileName, contentType string, data []byte) *RequestBuilder {
+	this.parts = append(this.parts, &multipartPart{
+		name:        name,
+		fileName:    fileName,
+		contentType: contentType,
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		},
+		rewindable: true,
+	})
+	return this
+}
+
+// multipart/form-data 中的本地文件，发送时才打开
+func (this *RequestBuilder) FilePath(name, path string) *RequestBuilder {
+	this.parts = append(this.parts, &multipartPart{
+		name:     name,
+		fileName: filepath.Base(path),
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		rewindable: true,
+	})
+	return this
+}
+
+func (this *RequestBuilder) GET(URL string) (*Response, error) {
+	return this.Send("GET", URL)
+}
+
+func (this *RequestBuilder) POST(URL string) (*Response, error) {
+	return this.Send("POST", URL)
+}
+
+func (this *RequestBuilder) PUT(URL string) (*Response, error) {
+	return this.Send("PUT", URL)
+}
+
+func (this *RequestBuilder) PATCH(URL string) (*Response, error) {
+	return this.Send("PATCH", URL)
+}
+
+func (this *RequestBuilder) DELETE(URL string) (*Response, error) {
+	return this.Send("DELETE", URL)
+}
+
+func (this *RequestBuilder) HEAD(URL string) (*Response, error) {
+	return this.Send("HEAD", URL)
+}
+
+func (this *RequestBuilder) OPTIONS(URL string) (*Response, error) {
+	return this.Send("OPTIONS", URL)
+}
+
+func (this *RequestBuilder) Send(method, URL string) (*Response, error) {
+	if len(this.query) > 0 {
+		u, err := url.Parse(URL)
+		if err != nil {
+			return nil, err
+		}
+		q := u.Query()
+		for key, vs := range this.query {
+			for _, v := range vs {
+				q.Add(key, v)
+			}
+		}
+		u.RawQuery = q.Encode()
+		URL = u.String()
+	}
+
+	hdr := this.request.headers.Clone()
+	for key, values := range this.header {
+		hdr[key] = values
+	}
+
+	var body io.Reader
+	if len(this.parts) > 0 {
+		var contentType string
+		body, contentType = this.multipartBody()
+		hdr.Set("Content-Type", contentType)
+	} else if this.body != nil {
+		var contentType string
+		var err error
+		if body, contentType, err = this.body(); err != nil {
+			return nil, err
+		}
+		if contentType != "" && this.header.Get("Content-Type") == "" {
+			hdr.Set("Content-Type", contentType)
+		}
+	}
+
+	return this.request.Do(method, URL, body, hdr, this.ctx)
+}
+
+func (this *RequestBuilder) setBody(body func() (io.Reader, string, error)) *RequestBuilder {
+	this.body = body
+	this.parts = nil
+	return this
+}
+
+// 边写边发送，所有部分都可以重新打开时，请求体可以重复生成
+func (this *RequestBuilder) multipartBody() (io.Reader, string) {
+	boundary := multipart.NewWriter(nil).Boundary()
+	parts := this.parts
+
+	open := func() (io.ReadCloser, error) {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(writeMultipart(pw, boundary, parts))
+		}()
+		return pr, nil
+	}
+
+	rewindable := true
+	for _, part := range parts {
+		rewindable = rewindable && part.rewindable
+	}
+
+	contentType := "multipart/form-data; boundary=" + boundary
+	if !rewindable {
+		return &lazyBody{open: open}, contentType
+	}
+
+	return &replayableBody{lazyBody: lazyBody{open: open}}, contentType
+}
+
+func writeMultipart(w io.Writer, boundary string, parts []*multipartPart) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		h := make(textproto.MIMEHeader)
+		disposition := "form-data; name=" + quoteField(part.name)
+		if part.fileName != "" {
+			disposition += "; filename=" + quoteField(part.fileName)
+
+			contentType := part.contentType
+			if contentType == "" {
+				contentType = mime.TypeByExtension(filepath.Ext(part.fileName))
+			}
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+			h.Set("Content-Type", contentType)
+		}
+		h.Set("Content-Disposition", disposition)
+
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+
+		r, err := part.open()
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(pw, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func quoteField(s string) string {
+	return `"` + quoteEscaper.Replace(s) + `"`
+}
+
+// 第一次读取时才调用 open，请求没有发出时不会启动写入的 goroutine
+type lazyBody struct {
+	open func() (io.ReadCloser, error)
+	rc   io.ReadCloser
+	err  error
+}
+
+func (this *lazyBody) Read(p []byte) (int, error) {
+	if this.rc == nil && this.err == nil {
+		this.rc, this.err = this.open()
+	}
+	if this.err != nil {
+		return 0, this.err
+	}
+
+	return this.rc.Read(p)
+}
+
+func (this *lazyBody) Close() error {
+	if this.rc == nil {
+		return nil
+	}
+
+	return this.rc.Close()
+}
+
+// 可以重复生成的请求体，重试和跳转时重新生成
+type replayableBody struct {
+	lazyBody
+}
+
+func (this *replayableBody) reopen() (io.ReadCloser, error) {
+	return &lazyBody{open: this.open}, nil
+}
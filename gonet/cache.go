@@ -0,0 +1,482 @@
+package gonet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 从缓存返回的响应会带上这个响应头
+const CacheHeader = "X-From-Cache"
+
+var ErrNotCached = errors.New("gonet: response is not cached")
+
+// 没有显式过期时间时，默认可以使用启发式过期时间的状态码 (RFC 7231 6.1)
+var heuristicStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMultipleChoices:      true,
+	http.StatusMovedPermanently:     true,
+	http.StatusPermanentRedirect:    true,
+	http.StatusNotFound:             true,
+	http.StatusMethodNotAllowed:     true,
+	http.StatusGone:                 true,
+	http.StatusRequestURITooLong:    true,
+	http.StatusNotImplemented:       true,
+}
+
+// Cache 按照 RFC 7234 缓存 GET 请求的响应，作为私有缓存使用，和浏览器的缓存类似。
+// 支持 Cache-Control、Expires、ETag/Last-Modified 验证以及 Vary。
+// 开启离线模式后，只从缓存中返回响应，不会访问网络
+type Cache struct {
+	store       CacheStore
+	offline     bool
+	maxBodySize int64
+}
+
+// 默认只缓存不超过 10MB 的响应体
+func NewCache(store CacheStore) *Cache {
+	return &Cache{
+		store:       store,
+		maxBodySize: 10 * 1024 * 1024,
+	}
+}
+
+// 离线模式，只从缓存中返回响应，没有缓存时返回 ErrNotCached
+func (this *Cache) EnableOffline() *Cache {
+	return this.Offline(true)
+}
+func (this *Cache) DisableOffline() *Cache {
+	return this.Offline(false)
+}
+func (this *Cache) Offline(offline bool) *Cache {
+	this.offline = offline
+	return this
+}
+
+// 超过这个大小的响应体不会被缓存。0 表示不限制
+func (this *Cache) SetMaxBodySize(maxBodySize int64) *Cache {
+	this.maxBodySize = maxBodySize
+	return this
+}
+
+// 删除 URL 对应的缓存
+func (this *Cache) Delete(URL string) error {
+	return this.store.Delete(URL)
+}
+
+// 包装 next，返回带缓存的 RoundTripper。next 为 nil 时使用 http.DefaultTransport
+func (this *Cache) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &cacheTransport{cache: this, next: next}
+}
+
+type cacheTransport struct {
+	cache *Cache
+	next  http.RoundTripper
+}
+
+func (this *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return this.cache.roundTrip(req, this.next)
+}
+
+func (this *Cache) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	key := req.URL.String()
+	reqCC := parseCacheControl(req.Header)
+
+	// 只缓存 GET 请求，Range 请求交给服务器处理
+	if req.Method != "GET" || req.Header.Get("Range") != "" || reqCC.has("no-store") {
+		if this.offline {
+			return nil, ErrNotCached
+		}
+
+		resp, err := next.RoundTrip(req)
+		// 修改了资源的请求成功后，缓存就失效了 (RFC 7234 4.4)
+		if err == nil && isUnsafeMethod(req.Method) && resp.StatusCode < 400 {
+			this.store.Delete(key)
+		}
+		return resp, err
+	}
+
+	entry, cached := this.load(key, req)
+	if this.offline {
+		if !cached {
+			return nil, ErrNotCached
+		}
+		return entry.response(req), nil
+	}
+
+	if cached && !reqCC.has("no-cache") && req.Header.Get("Pragma") != "no-cache" && entry.fresh(reqCC) {
+		return entry.response(req), nil
+	}
+
+	if reqCC.has("only-if-cached") {
+		if cached {
+			return entry.response(req), nil
+		}
+		return newGatewayTimeout(req), nil
+	}
+
+	outreq := req
+	if cached {
+		outreq = req.Clone(req.Context())
+		if etag := entry.Header.Get("ETag"); etag != "" && outreq.Header.Get("If-None-Match") == "" {
+			outreq.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := entry.Header.Get("Last-Modified"); lastModified != "" && outreq.Header.Get("If-Modified-Since") == "" {
+			outreq.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	requestTime := time.Now()
+	resp, err := next.RoundTrip(outreq)
+	if err != nil {
+		// 无法验证时，没有 must-revalidate 的缓存可以继续使用 (RFC 7234 4.2.4)
+		if cached && !parseCacheControl(entry.Header).has("must-revalidate") && req.Context().Err() == nil {
+			resp := entry.response(req)
+			resp.Header.Add("Warning", `111 - "Revalidation Failed"`)
+			return resp, nil
+		}
+		return nil, err
+	}
+	responseTime := time.Now()
+
+	if cached && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		entry.update(resp.Header, requestTime, responseTime)
+		this.save(key, entry)
+		return entry.response(req), nil
+	}
+
+	if !storable(resp) {
+		if cached {
+			this.store.Delete(key)
+		}
+		return resp, nil
+	}
+
+	entry = &cacheEntry{
+		StatusCode:   resp.StatusCode,
+		Status:       resp.Status,
+		Header:       resp.Header.Clone(),
+		RequestTime:  requestTime,
+		ResponseTime: responseTime,
+		Vary:         varyHeaders(resp.Header, req.Header),
+	}
+	// 响应体读取完成后再保存
+	resp.Body = &cacheBody{
+		ReadCloser: resp.Body,
+		limit:      this.maxBodySize,
+		done: func(body []byte) {
+			entry.Body = body
+			this.save(key, entry)
+		},
+	}
+
+	return resp, nil
+}
+
+func (this *Cache) load(key string, req *http.Request) (*cacheEntry, bool) {
+	data, ok := this.store.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, false
+	}
+
+	for name, value := range entry.Vary {
+		if req.Header.Get(name) != value {
+			return nil, false
+		}
+	}
+
+	return entry, true
+}
+
+func (this *Cache) save(key string, entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	this.store.Set(key, data)
+}
+
+type cacheEntry struct {
+	StatusCode   int               `json:"status_code"`
+	Status       string            `json:"status"`
+	Header       http.Header       `json:"header"`
+	Body         []byte            `json:"body"`
+	RequestTime  time.Time         `json:"request_time"`
+	ResponseTime time.Time         `json:"response_time"`
+	Vary         map[string]string `json:"vary"` // Vary 中的请求头和缓存时的值
+}
+
+func (this *cacheEntry) response(req *http.Request) *http.Response {
+	header := this.Header.Clone()
+	header.Set("Age", strconv.FormatInt(int64(this.age(time.Now())/time.Second), 10))
+	header.Set(CacheHeader, "1")
+
+	return &http.Response{
+		Status:        this.Status,
+		StatusCode:    this.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(this.Body)),
+		ContentLength: int64(len(this.Body)),
+		Request:       req,
+	}
+}
+
+// 用 304 响应的头更新缓存 (RFC 7234 4.3.4)
+func (this *cacheEntry) update(header http.Header, requestTime, responseTime time.Time) {
+	for name, values := range header {
+		if name == "Content-Length" {
+			continue
+		}
+		this.Header[name] = values
+	}
+
+	this.RequestTime = requestTime
+	this.ResponseTime = responseTime
+}
+
+func (this *cacheEntry) date() time.Time {
+	date, err := http.ParseTime(this.Header.Get("Date"))
+	if err != nil {
+		return this.ResponseTime
+	}
+
+	return date
+}
+
+// 过期时间 (RFC 7234 4.2.1)
+func (this *cacheEntry) freshnessLifetime() time.Duration {
+	if maxAge, ok := parseCacheControl(this.Header).seconds("max-age"); ok {
+		return maxAge
+	}
+
+	if expires := this.Header.Get("Expires"); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil {
+			return 0
+		}
+		return t.Sub(this.date())
+	}
+
+	// 启发式过期时间：Last-Modified 距今时间的 10%
+	if heuristicStatus[this.StatusCode] {
+		if lastModified, err := http.ParseTime(this.Header.Get("Last-Modified")); err == nil {
+			return this.date().Sub(lastModified) / 10
+		}
+	}
+
+	return 0
+}
+
+// 缓存的年龄 (RFC 7234 4.2.3)
+func (this *cacheEntry) age(now time.Time) time.Duration {
+	apparentAge := this.ResponseTime.Sub(this.date())
+	if apparentAge < 0 {
+		apparentAge = 0
+	}
+
+	ageValue := time.Duration(0)
+	if seconds, err := strconv.ParseInt(this.Header.Get("Age"), 10, 64); err == nil {
+		ageValue = time.Duration(seconds) * time.Second
+	}
+
+	correctedAge := ageValue + this.ResponseTime.Sub(this.RequestTime)
+	if correctedAge < apparentAge {
+		correctedAge = apparentAge
+	}
+
+	return correctedAge + now.Sub(this.ResponseTime)
+}
+
+func (this *cacheEntry) fresh(reqCC cacheControl) bool {
+	respCC := parseCacheControl(this.Header)
+	if respCC.has("no-cache") {
+		return false
+	}
+
+	lifetime := this.freshnessLifetime()
+	if maxAge, ok := reqCC.seconds("max-age"); ok && maxAge < lifetime {
+		lifetime = maxAge
+	}
+
+	age := this.age(time.Now())
+	if minFresh, ok := reqCC.seconds("min-fresh"); ok {
+		age += minFresh
+	}
+
+	if lifetime > age {
+		return true
+	}
+
+	if respCC.has("must-revalidate") || !reqCC.has("max-stale") {
+		return false
+	}
+
+	// max-stale 没有值时，可以接受任意过期的缓存
+	maxStale, ok := reqCC.seconds("max-stale")
+	return !ok || age-lifetime <= maxStale
+}
+
+// 响应是否可以缓存 (RFC 7234 3)
+func storable(resp *http.Response) bool {
+	cc := parseCacheControl(resp.Header)
+	if cc.has("no-store") {
+		return false
+	}
+
+	for _, field := range headerFields(resp.Header, "Vary") {
+		if field == "*" {
+			return false
+		}
+	}
+
+	_, hasMaxAge := cc["max-age"]
+	explicit := hasMaxAge || resp.Header.Get("Expires") != ""
+	switch {
+	case heuristicStatus[resp.StatusCode]:
+	case explicit && (resp.StatusCode == http.StatusFound || resp.StatusCode == http.StatusTemporaryRedirect):
+	default:
+		return false
+	}
+
+	// 既不能判断是否过期，也不能验证的响应，缓存了也没有用
+	return explicit ||
+		resp.Header.Get("ETag") != "" ||
+		resp.Header.Get("Last-Modified") != ""
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return false
+	}
+
+	return true
+}
+
+func varyHeaders(respHeader, reqHeader http.Header) map[string]string {
+	fields := headerFields(respHeader, "Vary")
+	if len(fields) == 0 {
+		return nil
+	}
+
+	vary := make(map[string]string, len(fields))
+	for _, field := range fields {
+		name := http.CanonicalHeaderKey(field)
+		vary[name] = reqHeader.Get(name)
+	}
+
+	return vary
+}
+
+// 把逗号分隔的响应头拆成字段
+func headerFields(header http.Header, name string) []string {
+	var fields []string
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+func newGatewayTimeout(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", http.StatusGatewayTimeout, http.StatusText(http.StatusGatewayTimeout)),
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}
+}
+
+// Cache-Control 的指令，没有值的指令对应空字符串
+type cacheControl map[string]string
+
+func parseCacheControl(header http.Header) cacheControl {
+	cc := cacheControl{}
+	for _, field := range headerFields(header, "Cache-Control") {
+		name, value := field, ""
+		if i := strings.Index(field, "="); i >= 0 {
+			name, value = field[:i], strings.Trim(field[i+1:], `"`)
+		}
+		cc[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+
+	return cc
+}
+
+func (this cacheControl) has(name string) bool {
+	_, ok := this[name]
+	return ok
+}
+
+func (this cacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := this[name]
+	if !ok {
+		return 0, false
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return time.Duration(seconds) * time.Second, true
+}
+
+// 读取响应体的同时保存一份，读取完成后交给 done。超过 limit 时不再保存
+type cacheBody struct {
+	io.ReadCloser
+	buf   bytes.Buffer
+	limit int64
+	done  func([]byte)
+	skip  bool
+}
+
+func (this *cacheBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if !this.skip && n > 0 {
+		if this.limit > 0 && int64(this.buf.Len()+n) > this.limit {
+			this.skip = true
+			this.buf = bytes.Buffer{}
+		} else {
+			this.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && !this.skip {
+		this.skip = true
+		this.done(this.buf.Bytes())
+	}
+
+	return n, err
+}
@@ -0,0 +1,297 @@
+package gonet_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// 记录发给服务器的请求，并用 handler 返回响应
+type cacheBackend struct {
+	requests []*http.Request
+	handler  func(req *http.Request) (*http.Response, error)
+}
+
+func (this *cacheBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	this.requests = append(this.requests, req)
+	return this.handler(req)
+}
+
+func cacheResponse(req *http.Request, code int, body string, header ...string) *http.Response {
+	resp := &http.Response{
+		StatusCode:    code,
+		Status:        http.StatusText(code),
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		resp.Header.Add(header[i], header[i+1])
+	}
+	return resp
+}
+
+// 读完响应体，读取完成后才会保存到缓存
+func cacheGet(t *testing.T, rt http.RoundTripper, method string, header ...string) (*http.Response, string) {
+	t.Helper()
+
+	req, _ := http.NewRequest(method, "http://example.com/data", nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestCacheFreshHit(t *testing.T) {
+	backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+		return cacheResponse(req, 200, "v1", "Cache-Control", "max-age=60"), nil
+	}}
+	rt := gonet.NewCache(gonet.NewMemoryCacheStore(0)).Transport(backend)
+
+	if resp, _ := cacheGet(t, rt, "GET"); resp.Header.Get(gonet.CacheHeader) != "" {
+		t.Fatal("first response is from cache")
+	}
+	resp, body := cacheGet(t, rt, "GET")
+	if body != "v1" || resp.Header.Get(gonet.CacheHeader) != "1" || resp.Header.Get("Age") == "" {
+		t.Fatalf("body = %q, header = %v", body, resp.Header)
+	}
+	if len(backend.requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(backend.requests))
+	}
+
+	// 请求中的 no-cache 需要重新验证
+	cacheGet(t, rt, "GET", "Cache-Control", "no-cache")
+	if len(backend.requests) != 2 {
+		t.Fatalf("%d requests, want 2", len(backend.requests))
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	tests := []struct {
+		validator string
+		value     string
+		condition string
+	}{
+		{"ETag", `"e1"`, "If-None-Match"},
+		{"Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT", "If-Modified-Since"},
+	}
+	for _, tt := range tests {
+		backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(tt.condition) == tt.value {
+				return cacheResponse(req, http.StatusNotModified, "", "X-Updated", "1"), nil
+			}
+			return cacheResponse(req, 200, "v1", "Cache-Control", "no-cache", tt.validator, tt.value), nil
+		}}
+		rt := gonet.NewCache(gonet.NewMemoryCacheStore(0)).Transport(backend)
+
+		cacheGet(t, rt, "GET")
+		resp, body := cacheGet(t, rt, "GET")
+		if len(backend.requests) != 2 || backend.requests[1].Header.Get(tt.condition) != tt.value {
+			t.Fatalf("%s: request was not revalidated", tt.validator)
+		}
+		if resp.StatusCode != 200 || body != "v1" || resp.Header.Get("X-Updated") != "1" || resp.Header.Get(gonet.CacheHeader) != "1" {
+			t.Fatalf("%s: %d %q %v", tt.validator, resp.StatusCode, body, resp.Header)
+		}
+
+		// 304 的响应头保存到了缓存中
+		if resp, _ := cacheGet(t, rt, "GET"); resp.Header.Get("X-Updated") != "1" {
+			t.Fatalf("%s: updated header was not stored", tt.validator)
+		}
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+		return cacheResponse(req, 200, req.Header.Get("Accept-Language"),
+			"Cache-Control", "max-age=60", "Vary", "Accept-Language"), nil
+	}}
+	rt := gonet.NewCache(gonet.NewMemoryCacheStore(0)).Transport(backend)
+
+	cacheGet(t, rt, "GET", "Accept-Language", "en")
+	if _, body := cacheGet(t, rt, "GET", "Accept-Language", "en"); body != "en" || len(backend.requests) != 1 {
+		t.Fatalf("body = %q, %d requests", body, len(backend.requests))
+	}
+	if _, body := cacheGet(t, rt, "GET", "Accept-Language", "fr"); body != "fr" || len(backend.requests) != 2 {
+		t.Fatalf("body = %q, %d requests", body, len(backend.requests))
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	store := gonet.NewMemoryCacheStore(0)
+	backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+		return cacheResponse(req, 200, "v1", "Cache-Control", "no-store, max-age=60"), nil
+	}}
+	rt := gonet.NewCache(store).Transport(backend)
+
+	cacheGet(t, rt, "GET")
+	cacheGet(t, rt, "GET")
+	if len(backend.requests) != 2 || store.Len() != 0 {
+		t.Fatalf("%d requests, %d entries", len(backend.requests), store.Len())
+	}
+}
+
+func TestCacheOffline(t *testing.T) {
+	backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+		return cacheResponse(req, 200, "v1", "Cache-Control", "max-age=0", "ETag", `"e1"`), nil
+	}}
+	cache := gonet.NewCache(gonet.NewMemoryCacheStore(0)).EnableOffline()
+	rt := cache.Transport(backend)
+
+	req, _ := http.NewRequest("GET", "http://example.com/data", nil)
+	if _, err := rt.RoundTrip(req); !errors.Is(err, gonet.ErrNotCached) {
+		t.Fatalf("err = %v, want ErrNotCached", err)
+	}
+
+	cache.DisableOffline()
+	cacheGet(t, rt, "GET")
+	cache.EnableOffline()
+
+	// 离线模式下过期的缓存也直接返回
+	if resp, body := cacheGet(t, rt, "GET"); body != "v1" || resp.Header.Get(gonet.CacheHeader) != "1" {
+		t.Fatalf("body = %q", body)
+	}
+	if len(backend.requests) != 1 {
+		t.Fatalf("%d requests, want 1", len(backend.requests))
+	}
+}
+
+func TestCacheStaleOnError(t *testing.T) {
+	for _, mustRevalidate := range []bool{false, true} {
+		cacheControl := "max-age=0"
+		if mustRevalidate {
+			cacheControl += ", must-revalidate"
+		}
+
+		fail := false
+		backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+			if fail {
+				return nil, errors.New("connection refused")
+			}
+			return cacheResponse(req, 200, "v1", "Cache-Control", cacheControl, "ETag", `"e1"`), nil
+		}}
+		rt := gonet.NewCache(gonet.NewMemoryCacheStore(0)).Transport(backend)
+
+		cacheGet(t, rt, "GET")
+		fail = true
+
+		req, _ := http.NewRequest("GET", "http://example.com/data", nil)
+		resp, err := rt.RoundTrip(req)
+		if mustRevalidate {
+			if err == nil {
+				t.Fatal("must-revalidate entry was served on error")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if !strings.HasPrefix(resp.Header.Get("Warning"), "111 ") {
+			t.Fatalf("Warning = %q", resp.Header.Get("Warning"))
+		}
+	}
+}
+
+func TestCacheUnsafeMethod(t *testing.T) {
+	backend := &cacheBackend{handler: func(req *http.Request) (*http.Response, error) {
+		return cacheResponse(req, 200, req.Method, "Cache-Control", "max-age=60"), nil
+	}}
+	rt := gonet.NewCache(gonet.NewMemoryCacheStore(0)).Transport(backend)
+
+	cacheGet(t, rt, "GET")
+	cacheGet(t, rt, "POST")
+	if _, body := cacheGet(t, rt, "GET"); body != "GET" || len(backend.requests) != 3 {
+		t.Fatalf("body = %q, %d requests, want 3", body, len(backend.requests))
+	}
+}
+
+func TestMemoryCacheStoreLRU(t *testing.T) {
+	store := gonet.NewMemoryCacheStore(2)
+	store.Set("a", []byte("1"))
+	store.Set("b", []byte("2"))
+	store.Get("a")
+	store.Set("c", []byte("3"))
+
+	if _, ok := store.Get("b"); ok {
+		t.Fatal("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := store.Get(key); !ok {
+			t.Fatalf("%s was evicted", key)
+		}
+	}
+	if store.Len() != 2 {
+		t.Fatalf("Len() = %d", store.Len())
+	}
+}
+
+func testCacheStore(t *testing.T, store gonet.CacheStore) {
+	t.Helper()
+
+	if _, ok := store.Get("http://example.com/a"); ok {
+		t.Fatal("empty store returned a value")
+	}
+	if err := store.Set("http://example.com/a", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("http://example.com/a", []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := store.Get("http://example.com/a"); !ok || string(value) != "v2" {
+		t.Fatalf("Get = %q, %v", value, ok)
+	}
+	if err := store.Delete("http://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("http://example.com/a"); ok {
+		t.Fatal("deleted value was returned")
+	}
+	if err := store.Delete("http://example.com/missing"); err != nil {
+		t.Fatalf("Delete missing key: %v", err)
+	}
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	store, err := gonet.NewDiskCacheStore(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCacheStore(t, store)
+}
+
+func TestBoltCacheStore(t *testing.T) {
+	dbfile := filepath.Join(t.TempDir(), "cache.db")
+	store, err := gonet.NewBoltCacheStore(dbfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCacheStore(t, store)
+
+	// 重新打开后数据还在
+	store.Set("key", []byte("value"))
+	store.Close()
+	store, err = gonet.NewBoltCacheStore(dbfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if value, ok := store.Get("key"); !ok || string(value) != "value" {
+		t.Fatalf("Get = %q, %v", value, ok)
+	}
+}
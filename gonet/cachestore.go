@@ -0,0 +1,189 @@
+package gonet
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/zhuomouren/gohelpers/gocrypto"
+	"github.com/zhuomouren/gohelpers/gofile"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var CacheBucket = []byte("httpcache")
+
+// CacheStore 保存 Cache 的数据，key 是请求的 URL
+type CacheStore interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte) error
+	Delete(key string) error
+}
+
+// MemoryCacheStore 保存在内存中，超过 maxEntries 时淘汰最久没有使用的数据
+type MemoryCacheStore struct {
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	lock       *sync.Mutex
+}
+
+type memoryCacheItem struct {
+	key   string
+	value []byte
+}
+
+// maxEntries 为 0 表示不限制
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		lock:       &sync.Mutex{},
+	}
+}
+
+func (this *MemoryCacheStore) Get(key string) ([]byte, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	e, ok := this.items[key]
+	if !ok {
+		return nil, false
+	}
+	this.ll.MoveToFront(e)
+
+	return e.Value.(*memoryCacheItem).value, true
+}
+
+func (this *MemoryCacheStore) Set(key string, value []byte) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if e, ok := this.items[key]; ok {
+		this.ll.MoveToFront(e)
+		e.Value.(*memoryCacheItem).value = value
+		return nil
+	}
+
+	this.items[key] = this.ll.PushFront(&memoryCacheItem{key: key, value: value})
+	if this.maxEntries > 0 && this.ll.Len() > this.maxEntries {
+		oldest := this.ll.Back()
+		this.ll.Remove(oldest)
+		delete(this.items, oldest.Value.(*memoryCacheItem).key)
+	}
+
+	return nil
+}
+
+func (this *MemoryCacheStore) Delete(key string) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if e, ok := this.items[key]; ok {
+		this.ll.Remove(e)
+		delete(this.items, key)
+	}
+
+	return nil
+}
+
+func (this *MemoryCacheStore) Len() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.ll.Len()
+}
+
+// DiskCacheStore 每个 key 保存为目录中的一个文件，文件名是 key 的 md5 值
+type DiskCacheStore struct {
+	dir string
+}
+
+func NewDiskCacheStore(dir string) (*DiskCacheStore, error) {
+	if err := gofile.Helper.Mkdir(dir); err != nil {
+		return nil, err
+	}
+
+	return &DiskCacheStore{dir: dir}, nil
+}
+
+func (this *DiskCacheStore) Get(key string) ([]byte, bool) {
+	data, err := ioutil.ReadFile(this.filename(key))
+	if err != nil {
+		return nil, false
+	}
+
+	return data, true
+}
+
+func (this *DiskCacheStore) Set(key string, value []byte) error {
+	return writeFileAtomic(this.filename(key), value)
+}
+
+func (this *DiskCacheStore) Delete(key string) error {
+	err := os.Remove(this.filename(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (this *DiskCacheStore) filename(key string) string {
+	return filepath.Join(this.dir, gocrypto.Helper.EncodeMd5(key))
+}
+
+// BoltCacheStore 保存在 bbolt 数据库文件中
+type BoltCacheStore struct {
+	db *bolt.DB
+}
+
+func NewBoltCacheStore(dbfile string) (*BoltCacheStore, error) {
+	db, err := bolt.Open(dbfile, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(CacheBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltCacheStore{db: db}, nil
+}
+
+func (this *BoltCacheStore) Get(key string) ([]byte, bool) {
+	var value []byte
+	this.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(CacheBucket).Get([]byte(key)); v != nil {
+			// v 只在事务中有效，需要复制一份
+			value = append([]byte(nil), v...)
+		}
+		return nil
+	})
+
+	return value, value != nil
+}
+
+func (this *BoltCacheStore) Set(key string, value []byte) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(CacheBucket).Put([]byte(key), value)
+	})
+}
+
+func (this *BoltCacheStore) Delete(key string) error {
+	return this.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(CacheBucket).Delete([]byte(key))
+	})
+}
+
+func (this *BoltCacheStore) Close() error {
+	return this.db.Close()
+}
@@ -0,0 +1,180 @@
+package gonet
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	metaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+?charset\s*=\s*["']?\s*([\w\-]+)`)
+
+	// 统计检测时依次尝试的编码，得分相同时排在前面的优先
+	detectCharsets = []string{"gb18030", "big5", "shift_jis", "euc-kr"}
+
+	boms = []struct {
+		bom     []byte
+		charset string
+	}{
+		{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+		{[]byte{0xFE, 0xFF}, "utf-16be"},
+		{[]byte{0xFF, 0xFE}, "utf-16le"},
+	}
+)
+
+const (
+	// 查找 <meta charset> 和统计检测时最多读取的长度
+	metaSniffSize   = 8 << 10
+	detectSniffSize = 64 << 10
+)
+
+// 检测 data 的编码，返回规范的编码名称，例如 utf-8、gbk、gb18030、big5。
+// 依次根据 contentType、BOM、<meta charset> 和内容统计检测，
+// 声明为 utf-8 但内容不是合法 utf-8 的，继续向下检测。
+// contentType 不是文本时返回空字符串
+func DetectCharset(data []byte, contentType string) string {
+	mediaType, params, _ := mime.ParseMediaType(contentType)
+	if !isTextMediaType(mediaType) {
+		return ""
+	}
+
+	if name := lookupCharset(params["charset"]); name != "" && (name != "utf-8" || utf8.Valid(data)) {
+		return name
+	}
+
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.bom) {
+			return b.charset
+		}
+	}
+
+	head := data
+	if len(head) > metaSniffSize {
+		head = head[:metaSniffSize]
+	}
+	if ar := metaCharsetPattern.FindSubmatch(head); len(ar) > 1 {
+		if name := lookupCharset(string(ar[1])); name != "" && (name != "utf-8" || utf8.Valid(data)) {
+			return name
+		}
+	}
+
+	return detectCharsetByContent(data)
+}
+
+// 转换成 utf-8，会去掉 BOM。name 为空或者不支持时原样返回
+func decodeCharset(data []byte, name string) []byte {
+	enc, name := charset.Lookup(name)
+	if enc == nil {
+		return data
+	}
+
+	for _, b := range boms {
+		if b.charset == name {
+			data = bytes.TrimPrefix(data, b.bom)
+		}
+	}
+	if name == "utf-8" {
+		return data
+	}
+
+	ret, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return data
+	}
+
+	return ret
+}
+
+func lookupCharset(label string) string {
+	if label == "" {
+		return ""
+	}
+
+	_, name := charset.Lookup(label)
+	return name
+}
+
+// 没有 Content-Type 的按文本处理
+func isTextMediaType(mediaType string) bool {
+	if mediaType == "" || strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "font/"),
+		mediaType == "application/octet-stream",
+		mediaType == "application/zip",
+		mediaType == "application/pdf":
+		return false
+	}
+
+	return true
+}
+
+// 合法的 utf-8 直接返回 utf-8，否则用每个候选编码解码，
+// 按解码出的本地文字加分、乱码和罕见字符减分，得分最高的就是结果。
+// 都不合适时返回 windows-1252
+func detectCharsetByContent(data []byte) string {
+	if utf8.Valid(data) {
+		return "utf-8"
+	}
+	if len(data) > detectSniffSize {
+		data = data[:detectSniffSize]
+	}
+
+	best, bestScore := "windows-1252", 0
+	for _, name := range detectCharsets {
+		if score := charsetScore(data, name); score > bestScore {
+			best, bestScore = name, score
+		}
+	}
+
+	return best
+}
+
+func charsetScore(data []byte, name string) int {
+	enc, _ := charset.Lookup(name)
+	text, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return 0
+	}
+
+	score := 0
+	prev := ' '
+	for _, r := range string(text) {
+		switch {
+		case r < utf8.RuneSelf:
+		case r == utf8.RuneError:
+			score -= 10
+		case unicode.Is(unicode.Han, r):
+			score++
+		case name == "shift_jis" && (unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r)) && !isHalfwidth(r):
+			// 日文中假名很常见，按 gbk 解码时会变成罕见汉字
+			score += 2
+		case name == "euc-kr" && unicode.Is(unicode.Hangul, r):
+			score++
+			// 韩文用空格分隔单词，中文按 euc-kr 解码出的韩文很少紧跟在空格后面
+			if prev == ' ' {
+				score += 2
+			}
+		case unicode.IsPunct(r) || unicode.IsSpace(r):
+		default:
+			score--
+		}
+		prev = r
+	}
+
+	return score
+}
+
+func isHalfwidth(r rune) bool {
+	return r >= 0xFF61 && r <= 0xFF9F
+}
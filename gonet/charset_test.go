@@ -0,0 +1,104 @@
+package gonet_test
+
+import (
+	"net/http"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+	"github.com/zhuomouren/gohelpers/gonet/gonettest"
+)
+
+func encodeString(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+
+	data, err := enc.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectCharsetByContent(t *testing.T) {
+	tests := []struct {
+		name string
+		enc  encoding.Encoding
+		text string
+		want string
+	}{
+		{"gbk", simplifiedchinese.GBK, "<html><body><p>第一章 天下大势，分久必合，合久必分。周末七国分争，并入于秦。</p></body></html>", "gb18030"},
+		{"gbk short", simplifiedchinese.GBK, "中文网页", "gb18030"},
+		{"big5", traditionalchinese.Big5, "<p>話說天下大勢，分久必合，合久必分。周末七國分爭，並入於秦。</p>", "big5"},
+		{"shift_jis", japanese.ShiftJIS, "<p>吾輩は猫である。名前はまだ無い。どこで生れたかとんと見当がつかぬ。</p>", "shift_jis"},
+		{"euc-kr", korean.EUCKR, "<p>대한민국 헌법 제1조 대한민국은 민주공화국이다. 모든 권력은 국민으로부터 나온다.</p>", "euc-kr"},
+	}
+	for _, tt := range tests {
+		data := encodeString(t, tt.enc, tt.text)
+		if got := gonet.DetectCharset(data, "text/html"); got != tt.want {
+			t.Errorf("%s: DetectCharset = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectCharsetPrecedence(t *testing.T) {
+	gbk := func(s string) string {
+		return string(encodeString(t, simplifiedchinese.GBK, s))
+	}
+	utf16 := "\xff\xfe<\x00p\x00>\x00"
+
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		want        string
+	}{
+		{"header", gbk("<meta charset=\"big5\">中文"), "text/html; charset=GBK", "gbk"},
+		{"header before bom", "\xef\xbb\xbf<p>abc</p>", "text/html; charset=big5", "big5"},
+		{"bom", utf16, "text/html", "utf-16le"},
+		{"bom before meta", "\xef\xbb\xbf<meta charset=\"gbk\"><p>中文</p>", "text/html", "utf-8"},
+		{"meta", gbk("<meta http-equiv=\"Content-Type\" content=\"text/html; charset=gb2312\"><p>中文</p>"), "text/html", "gbk"},
+		{"meta charset", "<meta charset='big5'><p>abc</p>", "", "big5"},
+		{"declared utf-8 but invalid", gbk("<p>第一章 天下大势，分久必合，合久必分。</p>"), "text/html; charset=utf-8", "gb18030"},
+		{"declared utf-8 falls through to meta", gbk("<meta charset=\"gbk\"><p>中文</p>"), "text/html; charset=utf-8", "gbk"},
+		{"meta utf-8 but invalid", gbk("<meta charset=\"utf-8\"><p>第一章 天下大势，分久必合，合久必分。</p>"), "text/html", "gb18030"},
+		{"unknown header charset", "<p>中文</p>", "text/html; charset=x-unknown", "utf-8"},
+		{"utf-8", "<p>中文网页</p>", "text/html", "utf-8"},
+		{"latin", "caf\xe9 cr\xe8me br\xfbl\xe9e", "text/plain", "windows-1252"},
+		{"binary", "\x89PNG\r\n", "image/png", ""},
+	}
+	for _, tt := range tests {
+		if got := gonet.DetectCharset([]byte(tt.data), tt.contentType); got != tt.want {
+			t.Errorf("%s: DetectCharset = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestResponseStringGBK(t *testing.T) {
+	mock := gonettest.NewMock()
+	body := encodeString(t, simplifiedchinese.GBK, "<html><body>第一章 天下大势，分久必合，合久必分。</body></html>")
+	mock.On("GET", "/gbk").ReplyBytes(200, body, http.Header{"Content-Type": {"text/html"}})
+
+	req := gonet.NewRequest().Use(mock.Middleware())
+	resp, err := req.Do("GET", "http://example.invalid/gbk", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := resp.String()
+	if err != nil || text != "<html><body>第一章 天下大势，分久必合，合久必分。</body></html>" {
+		t.Fatalf("String() = %q, %v", text, err)
+	}
+	if resp.Charset() != "gb18030" {
+		t.Fatalf("Charset() = %q", resp.Charset())
+	}
+
+	// 指定的编码优先
+	resp, _ = req.SetCharacterEncoding("gbk").Do("GET", "http://example.invalid/gbk", nil, nil, nil)
+	if resp.Charset() != "gbk" {
+		t.Fatalf("Charset() = %q, want gbk", resp.Charset())
+	}
+}
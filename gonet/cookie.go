@@ -0,0 +1,627 @@
+package gonet
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar 实现了 http.CookieJar，可以查看、导入和导出所有的 cookie。
+// 支持 JSON 和 Netscape cookies.txt (curl、wget 使用的格式) 两种文件格式
+type CookieJar struct {
+	entries   map[string]*cookieEntry // key: domain;path;name
+	fileName  string
+	saveDelay time.Duration
+	saveTimer *time.Timer // 等待中的自动保存
+	saveErr   error       // 最后一次自动保存的错误
+	logf      LogFunc
+	lock      *sync.RWMutex
+	saveLock  *sync.Mutex // 保证按顺序写入文件
+}
+
+type cookieEntry struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	Expires  time.Time `json:"expires"` // 零值表示会话 cookie
+	Secure   bool      `json:"secure"`
+	HttpOnly bool      `json:"http_only"`
+	HostOnly bool      `json:"host_only"` // 没有设置 Domain 时，只发送给设置它的主机
+	Created  time.Time `json:"created"`
+}
+
+func NewCookieJar() *CookieJar {
+	return &CookieJar{
+		entries:   make(map[string]*cookieEntry),
+		saveDelay: time.Second,
+		logf:      defaultLogf,
+		lock:      &sync.RWMutex{},
+		saveLock:  &sync.Mutex{},
+	}
+}
+
+// 打开保存在文件中的 cookie，文件不存在时创建一个空的 CookieJar。
+// 之后 cookie 变化时会保存到这个文件，见 SetSaveDelay，程序退出前需要调用 Flush。
+// 扩展名是 .txt 时使用 Netscape 格式，否则使用 JSON 格式
+func OpenCookieJar(fileName string) (*CookieJar, error) {
+	this := NewCookieJar()
+	if _, err := os.Stat(fileName); err == nil {
+		if err := this.load(fileName); err != nil {
+			return nil, err
+		}
+	}
+	this.fileName = fileName
+
+	return this, nil
+}
+
+// 自动保存前等待的时间，这段时间内的多次修改只写一次文件。默认是 1 秒，0 表示每次修改都立即保存
+func (this *CookieJar) SetSaveDelay(delay time.Duration) *CookieJar {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.saveDelay = delay
+	return this
+}
+
+// 自动保存失败时的日志
+func (this *CookieJar) Logf(logf LogFunc) *CookieJar {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	this.logf = logf
+	return this
+}
+
+// 立即保存还没有写入文件的修改。没有打开文件时什么都不做
+func (this *CookieJar) Flush() error {
+	this.lock.Lock()
+	if this.saveTimer != nil {
+		this.saveTimer.Stop()
+		this.saveTimer = nil
+	}
+	fileName := this.fileName
+	this.lock.Unlock()
+
+	if fileName == "" {
+		return nil
+	}
+
+	this.saveLock.Lock()
+	err := this.Save(fileName)
+	this.saveLock.Unlock()
+
+	this.lock.Lock()
+	this.saveErr = err
+	logf := this.logf
+	this.lock.Unlock()
+
+	if err != nil && logf != nil {
+		logf(ERROR, "save cookies to %s: %s", fileName, err)
+	}
+	return err
+}
+
+// 最后一次自动保存的错误
+func (this *CookieJar) SaveError() error {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return this.saveErr
+}
+
+func (this *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	this.lock.Lock()
+	for _, cookie := range cookies {
+		entry, ok := newCookieEntry(host, u.Path, cookie, now)
+		if !ok {
+			continue
+		}
+
+		key := entry.key()
+		if old, ok := this.entries[key]; ok {
+			entry.Created = old.Created
+		}
+		if entry.expired(now) {
+			delete(this.entries, key)
+		} else {
+			this.entries[key] = entry
+		}
+	}
+	this.lock.Unlock()
+
+	this.autoSave()
+}
+
+func (this *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host, err := canonicalHost(u.Host)
+	if err != nil {
+		return nil
+	}
+
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	https := u.Scheme == "https"
+	now := time.Now()
+
+	this.lock.RLock()
+	var selected []*cookieEntry
+	for _, entry := range this.entries {
+		if entry.expired(now) || (entry.Secure && !https) {
+			continue
+		}
+		if !entry.domainMatch(host) || !entry.pathMatch(p) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	this.lock.RUnlock()
+
+	// 路径长的排在前面，路径一样时先创建的排在前面 (RFC 6265 5.4)
+	sort.Slice(selected, func(i, j int) bool {
+		if len(selected[i].Path) != len(selected[j].Path) {
+			return len(selected[i].Path) > len(selected[j].Path)
+		}
+		return selected[i].Created.Before(selected[j].Created)
+	})
+
+	cookies := make([]*http.Cookie, 0, len(selected))
+	for _, entry := range selected {
+		cookies = append(cookies, &http.Cookie{Name: entry.Name, Value: entry.Value})
+	}
+
+	return cookies
+}
+
+// 所有保存了 cookie 的域名
+func (this *CookieJar) Domains() []string {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	seen := make(map[string]bool)
+	var domains []string
+	for _, entry := range this.entries {
+		if !seen[entry.Domain] {
+			seen[entry.Domain] = true
+			domains = append(domains, entry.Domain)
+		}
+	}
+	sort.Strings(domains)
+
+	return domains
+}
+
+// domain 下的所有 cookie，包括已经过期但还没有被清理的
+func (this *CookieJar) DomainCookies(domain string) []*http.Cookie {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	var cookies []*http.Cookie
+	for _, entry := range this.entries {
+		if entry.Domain == domain {
+			cookies = append(cookies, entry.cookie())
+		}
+	}
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name < cookies[j].Name
+	})
+
+	return cookies
+}
+
+// 所有的 cookie
+func (this *CookieJar) AllCookies() []*http.Cookie {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	cookies := make([]*http.Cookie, 0, len(this.entries))
+	for _, entry := range this.sortedEntries() {
+		cookies = append(cookies, entry.cookie())
+	}
+
+	return cookies
+}
+
+// 清除所有的 cookie
+func (this *CookieJar) Clear() {
+	this.lock.Lock()
+	this.entries = make(map[string]*cookieEntry)
+	this.lock.Unlock()
+
+	this.autoSave()
+}
+
+// 清除 domain 下的 cookie
+func (this *CookieJar) ClearDomain(domain string) {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+
+	this.lock.Lock()
+	for key, entry := range this.entries {
+		if entry.Domain == domain {
+			delete(this.entries, key)
+		}
+	}
+	this.lock.Unlock()
+
+	this.autoSave()
+}
+
+// 清除已经过期的 cookie
+func (this *CookieJar) ClearExpired() {
+	now := time.Now()
+
+	this.lock.Lock()
+	for key, entry := range this.entries {
+		if entry.expired(now) {
+			delete(this.entries, key)
+		}
+	}
+	this.lock.Unlock()
+
+	this.autoSave()
+}
+
+// 保存到文件，扩展名是 .txt 时使用 Netscape 格式，否则使用 JSON 格式
+func (this *CookieJar) Save(fileName string) error {
+	if strings.ToLower(filepath.Ext(fileName)) == ".txt" {
+		return this.SaveNetscape(fileName)
+	}
+
+	return this.SaveJSON(fileName)
+}
+
+// 从文件中导入，已有的同名 cookie 会被覆盖
+func (this *CookieJar) Load(fileName string) error {
+	if err := this.load(fileName); err != nil {
+		return err
+	}
+
+	this.autoSave()
+	return nil
+}
+
+func (this *CookieJar) SaveJSON(fileName string) error {
+	this.lock.RLock()
+	data, err := json.MarshalIndent(this.sortedEntries(), "", "  ")
+	this.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fileName, data)
+}
+
+func (this *CookieJar) LoadJSON(fileName string) error {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+
+	var entries []*cookieEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	this.add(entries)
+	this.autoSave()
+	return nil
+}
+
+// Netscape 格式，每行 7 个字段，用 tab 分隔：
+// domain, include subdomains, path, secure, expires, name, value
+func (this *CookieJar) SaveNetscape(fileName string) error {
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n\n")
+
+	this.lock.RLock()
+	for _, entry := range this.sortedEntries() {
+		domain := entry.Domain
+		if !entry.HostOnly {
+			domain = "." + domain
+		}
+		if entry.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		var expires int64
+		if !entry.Expires.IsZero() {
+			expires = entry.Expires.Unix()
+		}
+
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!entry.HostOnly), entry.Path, netscapeBool(entry.Secure), expires, entry.Name, entry.Value)
+	}
+	this.lock.RUnlock()
+
+	return writeFileAtomic(fileName, []byte(b.String()))
+}
+
+func (this *CookieJar) LoadNetscape(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var entries []*cookieEntry
+	now := time.Now()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+
+		entry := &cookieEntry{
+			Name:     fields[5],
+			Value:    fields[6],
+			Domain:   strings.TrimPrefix(strings.ToLower(fields[0]), "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Created:  now,
+		}
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			entry.Expires = time.Unix(expires, 0)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	this.add(entries)
+	this.autoSave()
+	return nil
+}
+
+func (this *CookieJar) load(fileName string) error {
+	this.lock.Lock()
+	autoSave := this.fileName
+	this.fileName = ""
+	this.lock.Unlock()
+
+	defer func() {
+		this.lock.Lock()
+		this.fileName = autoSave
+		this.lock.Unlock()
+	}()
+
+	if strings.ToLower(filepath.Ext(fileName)) == ".txt" {
+		return this.LoadNetscape(fileName)
+	}
+
+	return this.LoadJSON(fileName)
+}
+
+func (this *CookieJar) add(entries []*cookieEntry) {
+	now := time.Now()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, entry := range entries {
+		if entry.Name == "" || entry.Domain == "" || entry.expired(now) {
+			continue
+		}
+		if entry.Path == "" {
+			entry.Path = "/"
+		}
+		this.entries[entry.key()] = entry
+	}
+}
+
+func (this *CookieJar) sortedEntries() []*cookieEntry {
+	entries := make([]*cookieEntry, 0, len(this.entries))
+	for _, entry := range this.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key() < entries[j].key()
+	})
+
+	return entries
+}
+
+// 打开了文件时，在 saveDelay 之后保存，已经有等待中的保存时不重复保存
+func (this *CookieJar) autoSave() {
+	this.lock.Lock()
+	if this.fileName == "" || this.saveTimer != nil {
+		this.lock.Unlock()
+		return
+	}
+	if this.saveDelay > 0 {
+		this.saveTimer = time.AfterFunc(this.saveDelay, func() {
+			this.Flush()
+		})
+		this.lock.Unlock()
+		return
+	}
+	this.lock.Unlock()
+
+	this.Flush()
+}
+
+func newCookieEntry(host, urlPath string, cookie *http.Cookie, now time.Time) (*cookieEntry, bool) {
+	if cookie.Name == "" {
+		return nil, false
+	}
+
+	entry := &cookieEntry{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+		Created:  now,
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(cookie.Domain), ".")
+	if domain == "" || domain == host {
+		entry.Domain = host
+		entry.HostOnly = domain == ""
+	} else {
+		// 不能给其他域名或者公共后缀设置 cookie
+		if net.ParseIP(host) != nil || !strings.HasSuffix(host, "."+domain) {
+			return nil, false
+		}
+		if suffix, _ := publicsuffix.PublicSuffix(domain); suffix == domain {
+			return nil, false
+		}
+		entry.Domain = domain
+	}
+
+	entry.Path = cookie.Path
+	if entry.Path == "" || entry.Path[0] != '/' {
+		entry.Path = defaultCookiePath(urlPath)
+	}
+
+	switch {
+	case cookie.MaxAge < 0:
+		entry.Expires = time.Unix(1, 0)
+	case cookie.MaxAge > 0:
+		entry.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	case !cookie.Expires.IsZero():
+		entry.Expires = cookie.Expires
+	}
+
+	return entry, true
+}
+
+func (this *cookieEntry) key() string {
+	return this.Domain + ";" + this.Path + ";" + this.Name
+}
+
+func (this *cookieEntry) expired(now time.Time) bool {
+	return !this.Expires.IsZero() && !this.Expires.After(now)
+}
+
+func (this *cookieEntry) domainMatch(host string) bool {
+	if this.Domain == host {
+		return true
+	}
+
+	return !this.HostOnly && strings.HasSuffix(host, "."+this.Domain)
+}
+
+// RFC 6265 5.1.4
+func (this *cookieEntry) pathMatch(requestPath string) bool {
+	if requestPath == this.Path {
+		return true
+	}
+
+	if strings.HasPrefix(requestPath, this.Path) {
+		return this.Path[len(this.Path)-1] == '/' || requestPath[len(this.Path)] == '/'
+	}
+
+	return false
+}
+
+func (this *cookieEntry) cookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     this.Name,
+		Value:    this.Value,
+		Domain:   this.Domain,
+		Path:     this.Path,
+		Expires:  this.Expires,
+		Secure:   this.Secure,
+		HttpOnly: this.HttpOnly,
+	}
+}
+
+// RFC 6265 5.1.4
+func defaultCookiePath(urlPath string) string {
+	if urlPath == "" || urlPath[0] != '/' {
+		return "/"
+	}
+
+	dir := path.Dir(urlPath)
+	if urlPath[len(urlPath)-1] == '/' {
+		dir = strings.TrimSuffix(urlPath, "/")
+	}
+	if dir == "" || dir == "." {
+		return "/"
+	}
+
+	return dir
+}
+
+func canonicalHost(host string) (string, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", fmt.Errorf("gonet: empty cookie host")
+	}
+
+	return host, nil
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
+}
+
+// 先写入临时文件再重命名，避免写了一半的文件覆盖原来的文件
+func writeFileAtomic(fileName string, data []byte) error {
+	dir := filepath.Dir(fileName)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, filepath.Base(fileName)+".tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), fileName)
+}
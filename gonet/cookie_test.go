@@ -0,0 +1,70 @@
+package gonet
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCookieJarAutoSave(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "cookies.json")
+	jar, err := OpenCookieJar(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jar.SetSaveDelay(time.Hour)
+
+	u, _ := url.Parse("http://www.example.com/")
+	for i := 0; i < 10; i++ {
+		jar.SetCookies(u, []*http.Cookie{{Name: "n", Value: string(rune('a' + i))}})
+	}
+	if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+		t.Fatal("saved before the delay")
+	}
+
+	if err := jar.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := OpenCookieJar(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := loaded.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Value != "j" {
+		t.Fatalf("cookies = %v", cookies)
+	}
+}
+
+func TestCookieJarSaveError(t *testing.T) {
+	dir := t.TempDir()
+	// 父目录是一个文件，无法保存
+	ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0666)
+
+	jar, err := OpenCookieJar(filepath.Join(dir, "file", "cookies.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := make(chan LogLevel, 1)
+	jar.SetSaveDelay(time.Millisecond).Logf(func(lvl LogLevel, f string, args ...interface{}) {
+		logged <- lvl
+	})
+
+	u, _ := url.Parse("http://www.example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "n", Value: "v"}})
+
+	select {
+	case lvl := <-logged:
+		if lvl != ERROR {
+			t.Fatalf("logged %s", lvl)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("save error was not logged")
+	}
+	if jar.SaveError() == nil {
+		t.Fatal("SaveError() = nil")
+	}
+}
@@ -0,0 +1,231 @@
+package gonet
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Decoder 解码按某种 Content-Encoding 压缩的响应体
+type Decoder func(r io.Reader) (io.ReadCloser, error)
+
+// Decoders 是 Content-Encoding 到 Decoder 的注册表。
+// 请求没有设置 Accept-Encoding 时，自动设置为注册的所有编码；
+// 响应按 Content-Encoding 逐层解码，有不认识的编码时不解码
+type Decoders struct {
+	encodings []string
+	decoders  map[string]Decoder
+	lock      *sync.RWMutex
+}
+
+// 包含 gzip、deflate、br 和 zstd
+func NewDecoders() *Decoders {
+	return (&Decoders{
+		decoders: make(map[string]Decoder),
+		lock:     &sync.RWMutex{},
+	}).
+		Register("gzip", GzipDecoder).
+		Register("deflate", DeflateDecoder).
+		Register("br", BrotliDecoder).
+		Register("zstd", ZstdDecoder)
+}
+
+// 注册 encoding 的解码器，已经存在时替换
+func (this *Decoders) Register(encoding string, decoder Decoder) *Decoders {
+	encoding = strings.ToLower(encoding)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.decoders[encoding]; !ok {
+		this.encodings = append(this.encodings, encoding)
+	}
+	this.decoders[encoding] = decoder
+	return this
+}
+
+func (this *Decoders) Remove(encoding string) *Decoders {
+	encoding = strings.ToLower(encoding)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if _, ok := this.decoders[encoding]; !ok {
+		return this
+	}
+	delete(this.decoders, encoding)
+	for i, e := range this.encodings {
+		if e == encoding {
+			this.encodings = append(this.encodings[:i:i], this.encodings[i+1:]...)
+			break
+		}
+	}
+	return this
+}
+
+func (this *Decoders) clone() *Decoders {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	decoders := make(map[string]Decoder, len(this.decoders))
+	for encoding, decoder := range this.decoders {
+		decoders[encoding] = decoder
+	}
+
+	return &Decoders{
+		encodings: append([]string{}, this.encodings...),
+		decoders:  decoders,
+		lock:      &sync.RWMutex{},
+	}
+}
+
+// Accept-Encoding 请求头的值，按注册的顺序排列
+func (this *Decoders) AcceptEncoding() string {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return strings.Join(this.encodings, ", ")
+}
+
+func (this *Decoders) get(encoding string) (Decoder, bool) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	decoder, ok := this.decoders[encoding]
+	return decoder, ok
+}
+
+// 返回解码响应体的中间件。
+// 请求中有 Range 时不设置 Accept-Encoding，避免范围和压缩后的内容对应不上
+func (this *Decoders) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Accept-Encoding") == "" && req.Header.Get("Range") == "" {
+				if accept := this.AcceptEncoding(); accept != "" {
+					req = req.Clone(req.Context())
+					req.Header.Set("Accept-Encoding", accept)
+				}
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil || resp.Uncompressed {
+				return resp, err
+			}
+
+			if err := this.decode(resp); err != nil {
+				resp.Body.Close()
+				return nil, err
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+func (this *Decoders) decode(resp *http.Response) error {
+	// Content-Encoding 按压缩的先后顺序排列，解码时倒过来
+	var decoders []Decoder
+	for _, value := range resp.Header["Content-Encoding"] {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding == "" || encoding == "identity" {
+				continue
+			}
+
+			decoder, ok := this.get(encoding)
+			if !ok {
+				return nil
+			}
+			decoders = append([]Decoder{decoder}, decoders...)
+		}
+	}
+	if len(decoders) == 0 {
+		return nil
+	}
+
+	resp.Body = &decodeBody{body: resp.Body, decoders: decoders}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	// 解码后的长度未知，和 http.Transport 自动解压时的处理一致
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+
+	return nil
+}
+
+// 第一次读取时才创建解码器，响应体为空时不会出错
+type decodeBody struct {
+	body     io.ReadCloser
+	decoders []Decoder
+	readers  []io.ReadCloser
+	r        io.Reader
+	err      error
+}
+
+func (this *decodeBody) Read(p []byte) (int, error) {
+	if this.r == nil && this.err == nil {
+		this.r = this.body
+		for _, decoder := range this.decoders {
+			var rc io.ReadCloser
+			rc, this.err = decoder(this.r)
+			if this.err != nil {
+				break
+			}
+			this.readers = append(this.readers, rc)
+			this.r = rc
+		}
+	}
+	if this.err != nil {
+		return 0, this.err
+	}
+
+	return this.r.Read(p)
+}
+
+func (this *decodeBody) Close() error {
+	for i := len(this.readers) - 1; i >= 0; i-- {
+		this.readers[i].Close()
+	}
+
+	return this.body.Close()
+}
+
+func GzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// HTTP 的 deflate 应该是 zlib 格式，但有些服务器直接发送 deflate 数据，两种都支持
+func DeflateDecoder(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+func BrotliDecoder(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(brotli.NewReader(r)), nil
+}
+
+func ZstdDecoder(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return d.IOReadCloser(), nil
+}
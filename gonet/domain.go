@@ -0,0 +1,135 @@
+package gonet
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// 下面的方法使用 golang.org/x/net/publicsuffix 中内置的 Public Suffix List（https://publicsuffix.org/），
+// 参数可以是域名，也可以是 URL。域名不区分大小写，国际化域名会先转换成 punycode
+
+var ErrInvalidDomain = errors.New("gonet: invalid domain")
+
+// 公共后缀（eTLD），例如 www.example.com.cn 的公共后缀是 com.cn。
+// icann 为 false 表示是私有的后缀（例如 github.io）或者不在列表中
+func (this *GoURL) PublicSuffix(domain string) (suffix string, icann bool, err error) {
+	if domain, err = this.domainOf(domain); err != nil {
+		return "", false, err
+	}
+
+	suffix, icann = publicsuffix.PublicSuffix(domain)
+	return suffix, icann, nil
+}
+
+// 可以注册的域名（eTLD+1），例如 m.example.com.cn 的可注册域名是 example.com.cn。
+// 域名本身是公共后缀时返回错误
+func (this *GoURL) RegistrableDomain(domain string) (string, error) {
+	domain, err := this.domainOf(domain)
+	if err != nil {
+		return "", err
+	}
+
+	return publicsuffix.EffectiveTLDPlusOne(domain)
+}
+
+// 可注册域名之前的部分，例如 m.news.example.com.cn 的子域名是 m.news，没有子域名时返回空字符串
+func (this *GoURL) Subdomain(domain string) (string, error) {
+	domain, err := this.domainOf(domain)
+	if err != nil {
+		return "", err
+	}
+
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(strings.TrimSuffix(domain, registrable), "."), nil
+}
+
+// domain 是否是 parent 的子域名，相同时也返回 true
+func (this *GoURL) IsSubdomain(domain, parent string) bool {
+	domain, err := this.domainOf(domain)
+	if err != nil {
+		return false
+	}
+	parent, err = this.domainOf(parent)
+	if err != nil {
+		return false
+	}
+
+	return domain == parent || strings.HasSuffix(domain, "."+parent)
+}
+
+// 两个域名的可注册域名是否相同，例如 m.example.com.cn 和 www.example.com.cn。
+// IP 地址只有相同时才返回 true
+func (this *GoURL) SameSite(a, b string) bool {
+	a, err := this.domainOf(a)
+	if err != nil {
+		return false
+	}
+	b, err = this.domainOf(b)
+	if err != nil {
+		return false
+	}
+	if net.ParseIP(a) != nil || net.ParseIP(b) != nil {
+		return a == b
+	}
+
+	siteA, err := publicsuffix.EffectiveTLDPlusOne(a)
+	if err != nil {
+		return false
+	}
+	siteB, err := publicsuffix.EffectiveTLDPlusOne(b)
+	if err != nil {
+		return false
+	}
+
+	return siteA == siteB
+}
+
+// 国际化域名转换成 punycode，例如 例子.测试 => xn--fsqu00a.xn--0zwm56d
+func (this *GoURL) ToASCII(domain string) (string, error) {
+	return idnaProfile.ToASCII(domain)
+}
+
+// punycode 转换成国际化域名，例如 xn--fsqu00a.xn--0zwm56d => 例子.测试
+func (this *GoURL) ToUnicode(domain string) (string, error) {
+	return idnaProfile.ToUnicode(domain)
+}
+
+// 取出 URL 中的主机名，去掉端口和最后的点，转换成小写的 punycode
+func (this *GoURL) domainOf(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "", err
+		}
+		s = u.Hostname()
+	} else if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+
+	s = strings.TrimSuffix(strings.Trim(s, "[]"), ".")
+	if s == "" {
+		return "", ErrInvalidDomain
+	}
+	if net.ParseIP(s) != nil {
+		return s, nil
+	}
+
+	domain, err := idnaProfile.ToASCII(s)
+	if err != nil {
+		return "", err
+	}
+	if !this.IsDomainName(domain) {
+		return "", ErrInvalidDomain
+	}
+
+	return domain, nil
+}
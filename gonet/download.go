@@ -0,0 +1,227 @@
+package gonet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zhuomouren/gohelpers/gocrypto"
+	"github.com/zhuomouren/gohelpers/gofile"
+)
+
+const (
+	partSuffix = ".part"
+	metaSuffix = ".meta"
+)
+
+var ErrChecksumMismatch = errors.New("gonet: checksum mismatch")
+
+// Checksum 用于校验下载完成的文件
+type Checksum struct {
+	algorithm string
+	sum       string
+}
+
+// sum 是十六进制的 md5 值
+func MD5Checksum(sum string) *Checksum {
+	return &Checksum{algorithm: "md5", sum: sum}
+}
+
+// sum 是十六进制的 sha256 值
+func SHA256Checksum(sum string) *Checksum {
+	return &Checksum{algorithm: "sha256", sum: sum}
+}
+
+// sum 是十六进制的 crc32 (IEEE) 值
+func CRC32Checksum(sum string) *Checksum {
+	return &Checksum{algorithm: "crc32", sum: sum}
+}
+
+func (this *Checksum) Verify(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sum string
+	switch this.algorithm {
+	case "md5":
+		sum, err = gocrypto.Helper.FileMd5(f)
+	case "sha256":
+		sum, err = gocrypto.Helper.FileSha256(f)
+	case "crc32":
+		var crc uint32
+		crc, err = gofile.Helper.CRC32(f)
+		sum = fmt.Sprintf("%08x", crc)
+	default:
+		return fmt.Errorf("gonet: unknown checksum algorithm %q", this.algorithm)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(sum, this.sum) {
+		return fmt.Errorf("%w: %s is %s, want %s", ErrChecksumMismatch, this.algorithm, sum, this.sum)
+	}
+
+	return nil
+}
+
+// 下载文件，支持断点续传。
+// 数据先写入 fileName.part，服务器返回的 ETag 或 Last-Modified 保存在 fileName.part.meta，
+// 下次下载时通过 Range 和 If-Range 从中断的位置继续；文件在服务器上变化了则重新下载。
+// 下载完成并且校验通过后，重命名为 fileName
+func (this *Request) Download(URL, fileName string, checksums ...*Checksum) error {
+	return this.DownloadContext(nil, URL, fileName, checksums...)
+}
+
+func (this *Request) DownloadContext(ctx context.Context, URL, fileName string, checksums ...*Checksum) error {
+	part := fileName + partSuffix
+	meta := part + metaSuffix
+
+	var offset int64
+	validator := readValidator(meta)
+	if info, err := os.Stat(part); err == nil && validator != "" {
+		offset = info.Size()
+	}
+
+	hdr := this.headers.Clone()
+	// 续传的是原始字节，不能让服务器压缩
+	hdr.Set("Accept-Encoding", "identity")
+	if offset > 0 {
+		hdr.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		hdr.Set("If-Range", validator)
+	}
+
+	resp, err := this.Do("GET", URL, nil, hdr, ctx)
+	if err != nil {
+		return err
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusPartialContent:
+		start, _, _, ok := parseContentRange(resp.Header())
+		if !ok || start != offset {
+			resp.Close()
+			return fmt.Errorf("gonet: download %s: unexpected Content-Range %q", URL, resp.Header().Get("Content-Range"))
+		}
+	case http.StatusOK:
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		resp.Close()
+		// .part 已经下载完整了
+		if _, _, size, ok := parseContentRange(resp.Header()); ok && size == offset {
+			return finishDownload(part, meta, fileName, checksums)
+		}
+		if offset == 0 {
+			return fmt.Errorf("gonet: download %s: %s", URL, resp.Raw().Status)
+		}
+
+		os.Remove(part)
+		os.Remove(meta)
+		return this.DownloadContext(ctx, URL, fileName, checksums...)
+	default:
+		resp.Close()
+		return fmt.Errorf("gonet: download %s: %s", URL, resp.Raw().Status)
+	}
+
+	if validator := validatorOf(resp.Header()); validator != "" {
+		if err := ioutil.WriteFile(meta, []byte(validator), 0666); err != nil {
+			resp.Close()
+			return err
+		}
+	} else {
+		os.Remove(meta)
+	}
+
+	// 出错时保留 .part，下次从中断的位置继续
+	if err := resp.writeFile(part, offset); err != nil {
+		return err
+	}
+
+	return finishDownload(part, meta, fileName, checksums)
+}
+
+func finishDownload(part, meta, fileName string, checksums []*Checksum) error {
+	for _, checksum := range checksums {
+		if err := checksum.Verify(part); err != nil {
+			os.Remove(part)
+			os.Remove(meta)
+			return err
+		}
+	}
+
+	if err := os.Rename(part, fileName); err != nil {
+		return err
+	}
+	os.Remove(meta)
+
+	return nil
+}
+
+func readValidator(meta string) string {
+	data, err := ioutil.ReadFile(meta)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// If-Range 只能使用强 ETag，没有的话使用 Last-Modified
+func validatorOf(header http.Header) string {
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return header.Get("Last-Modified")
+}
+
+// 解析 Content-Range: bytes 0-499/1234，总长度未知时 size 为 -1。
+// 416 响应的 bytes */1234 中 start 和 end 为 -1
+func parseContentRange(header http.Header) (start, end, size int64, ok bool) {
+	value := header.Get("Content-Range")
+	if !strings.HasPrefix(value, "bytes ") {
+		return 0, 0, 0, false
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, "bytes "), "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, false
+	}
+
+	size = -1
+	if parts[1] != "*" {
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		size = n
+	}
+
+	if parts[0] == "*" {
+		return -1, -1, size, true
+	}
+
+	bounds := strings.SplitN(parts[0], "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	end, err = strconv.ParseInt(bounds[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	return start, end, size, true
+}
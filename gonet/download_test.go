@@ -0,0 +1,143 @@
+package gonet_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+	"github.com/zhuomouren/gohelpers/gonet/gonettest"
+)
+
+var downloadContent = bytes.Repeat([]byte("0123456789"), 10000)
+
+func downloadChecksum() *gonet.Checksum {
+	sum := sha256.Sum256(downloadContent)
+	return gonet.SHA256Checksum(hex.EncodeToString(sum[:]))
+}
+
+func assertDownloaded(t *testing.T, fileName string) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, downloadContent) {
+		t.Fatalf("downloaded %d bytes, want %d", len(data), len(downloadContent))
+	}
+	for _, name := range []string{fileName + ".part", fileName + ".part.meta"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", name)
+		}
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	modtime := time.Now()
+	mock.On("GET", "/file").Once().ResetAfter(30000).ReplyContent(downloadContent, modtime, `"v1"`)
+	mock.On("GET", "/file").WithHeader("Range", "bytes=30000-").WithHeader("If-Range", `"v1"`).Once().
+		ReplyContent(downloadContent, modtime, `"v1"`)
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	req := gonet.NewRequest()
+	var current, total int64
+	req.OnDownload(func(c, t int64) {
+		current, total = c, t
+	})
+
+	if err := req.Download(mock.URL+"/file", fileName, downloadChecksum()); err == nil {
+		t.Fatal("interrupted download returned no error")
+	}
+	if info, err := os.Stat(fileName + ".part"); err != nil || info.Size() != 30000 {
+		t.Fatalf("partial file: %v, %v", info, err)
+	}
+
+	if err := req.Download(mock.URL+"/file", fileName, downloadChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+	if current != int64(len(downloadContent)) || total != int64(len(downloadContent)) {
+		t.Fatalf("progress = %d/%d", current, total)
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestDownloadChangedFile(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/file").Once().ReplyContent(downloadContent, time.Now(), `"v2"`)
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	ioutil.WriteFile(fileName+".part", []byte("stale data"), 0666)
+	ioutil.WriteFile(fileName+".part.meta", []byte(`"v1"`), 0666)
+
+	if err := gonet.NewRequest().Download(mock.URL+"/file", fileName, downloadChecksum()); err != nil {
+		t.Fatal(err)
+	}
+	assertDownloaded(t, fileName)
+}
+
+func TestDownloadRangeNotSatisfiable(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		mock := gonettest.NewServer()
+		defer mock.Close()
+
+		mock.On("GET", "/file").Once().ReplyContent(downloadContent, time.Now(), `"v1"`)
+
+		fileName := filepath.Join(t.TempDir(), "file")
+		ioutil.WriteFile(fileName+".part", downloadContent, 0666)
+		ioutil.WriteFile(fileName+".part.meta", []byte(`"v1"`), 0666)
+
+		if err := gonet.NewRequest().Download(mock.URL+"/file", fileName, downloadChecksum()); err != nil {
+			t.Fatal(err)
+		}
+		assertDownloaded(t, fileName)
+		mock.AssertExpectations(t)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		mock := gonettest.NewServer()
+		defer mock.Close()
+
+		mock.On("GET", "/file").Times(2).ReplyContent(downloadContent, time.Now(), `"v1"`)
+
+		fileName := filepath.Join(t.TempDir(), "file")
+		ioutil.WriteFile(fileName+".part", bytes.Repeat([]byte("x"), 2*len(downloadContent)), 0666)
+		ioutil.WriteFile(fileName+".part.meta", []byte(`"v1"`), 0666)
+
+		if err := gonet.NewRequest().Download(mock.URL+"/file", fileName, downloadChecksum()); err != nil {
+			t.Fatal(err)
+		}
+		assertDownloaded(t, fileName)
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestDownloadChecksumMismatch(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/file").ReplyContent(downloadContent, time.Now(), `"v1"`)
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	err := gonet.NewRequest().Download(mock.URL+"/file", fileName, gonet.MD5Checksum("00"))
+	if !errors.Is(err, gonet.ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+	for _, name := range []string{fileName, fileName + ".part", fileName + ".part.meta"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", name)
+		}
+	}
+}
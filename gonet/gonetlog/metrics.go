@@ -0,0 +1,35 @@
+// gonetlog 把 gonet 的请求记录写到 golog。
+// 单独放在这里，使用 gonet 时不需要依赖 zap：
+//
+//	req := gonet.NewRequest().SetMetricsSink(gonetlog.MetricsSink(golog.New("crawler")))
+package gonetlog
+
+import (
+	"github.com/zhuomouren/gohelpers/golog"
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// 把请求记录写到 golog，请求出错时使用 Warn，否则使用 Info
+func MetricsSink(logger *golog.Logger) gonet.MetricsSink {
+	return gonet.MetricsSinkFunc(func(m *gonet.Metrics) {
+		log, errMsg := logger.Info, ""
+		if m.Err != nil {
+			log, errMsg = logger.Warn, m.Err.Error()
+		}
+
+		log("http request",
+			golog.String("method", m.Method),
+			golog.String("url", m.URL),
+			golog.Int("status", m.StatusCode),
+			golog.Duration("dns", m.Timing.DNS),
+			golog.Duration("connect", m.Timing.Connect),
+			golog.Duration("tls", m.Timing.TLS),
+			golog.Duration("ttfb", m.Timing.TTFB),
+			golog.Duration("transfer", m.Timing.Transfer),
+			golog.Duration("total", m.Timing.Total),
+			golog.Any("reused", m.Timing.Reused),
+			golog.String("remote_addr", m.Timing.RemoteAddr),
+			golog.String("error", errMsg),
+		)
+	})
+}
@@ -0,0 +1,420 @@
+package gonettest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// 模拟连接被重置时 Transport 返回的错误
+var ErrConnectionReset = &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+// Expectation 描述一个预期的请求和它的响应
+type Expectation struct {
+	method   string
+	path     string
+	matchers []func(r *http.Request, body []byte) bool
+
+	times int // 0 表示不限次数，但至少要有一次
+	calls int
+	lock  *sync.Mutex // Mock 的锁
+
+	handler    http.Handler
+	delay      time.Duration
+	reset      bool
+	resetAfter int
+	chunkSize  int
+	interval   time.Duration
+}
+
+func newExpectation(method, path string, lock *sync.Mutex) *Expectation {
+	if method == "*" {
+		method = ""
+	}
+
+	return &Expectation{
+		method:     strings.ToUpper(method),
+		path:       path,
+		handler:    replyHandler(http.StatusOK, nil, nil),
+		resetAfter: -1,
+		lock:       lock,
+	}
+}
+
+// 请求头 name 的值等于 value
+func (this *Expectation) WithHeader(name, value string) *Expectation {
+	return this.Match(func(r *http.Request, body []byte) bool {
+		return r.Header.Get(name) == value
+	})
+}
+
+// 查询参数 name 的值等于 value
+func (this *Expectation) WithQuery(name, value string) *Expectation {
+	return this.Match(func(r *http.Request, body []byte) bool {
+		return r.URL.Query().Get(name) == value
+	})
+}
+
+func (this *Expectation) WithBody(body string) *Expectation {
+	return this.Match(func(r *http.Request, b []byte) bool {
+		return string(b) == body
+	})
+}
+
+func (this *Expectation) WithBodyContains(s string) *Expectation {
+	return this.Match(func(r *http.Request, b []byte) bool {
+		return bytes.Contains(b, []byte(s))
+	})
+}
+
+// 请求体是 JSON，并且和 v 序列化后的内容相同
+func (this *Expectation) WithJSON(v interface{}) *Expectation {
+	var want interface{}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, &want)
+	}
+
+	return this.Match(func(r *http.Request, b []byte) bool {
+		var got interface{}
+		return err == nil && json.Unmarshal(b, &got) == nil && reflect.DeepEqual(got, want)
+	})
+}
+
+// 自定义的匹配条件，body 是请求体
+func (this *Expectation) Match(f func(r *http.Request, body []byte) bool) *Expectation {
+	this.matchers = append(this.matchers, f)
+	return this
+}
+
+// 必须正好匹配 n 次，达到次数后不再匹配
+func (this *Expectation) Times(n int) *Expectation {
+	this.times = n
+	return this
+}
+
+func (this *Expectation) Once() *Expectation {
+	return this.Times(1)
+}
+
+// 已经匹配的次数
+func (this *Expectation) Calls() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.calls
+}
+
+func (this *Expectation) Reply(status int, body string) *Expectation {
+	return this.ReplyBytes(status, []byte(body), nil)
+}
+
+func (this *Expectation) ReplyBytes(status int, body []byte, header http.Header) *Expectation {
+	this.handler = replyHandler(status, body, header)
+	return this
+}
+
+func (this *Expectation) ReplyJSON(status int, v interface{}) *Expectation {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return this.Reply(http.StatusInternalServerError, err.Error())
+	}
+
+	return this.ReplyBytes(status, body, http.Header{"Content-Type": []string{"application/json; charset=utf-8"}})
+}
+
+// 用 http.ServeContent 返回 content，支持 Range 和 If-Range，适合测试下载。
+// etag 不为空时设置 ETag 响应头
+func (this *Expectation) ReplyContent(content []byte, modtime time.Time, etag string) *Expectation {
+	return this.ReplyFunc(func(w http.ResponseWriter, r *http.Request) {
+		if etag != "" {
+			w.Header().Set("ETag", etag)
+		}
+		http.ServeContent(w, r, "", modtime, bytes.NewReader(content))
+	})
+}
+
+func (this *Expectation) ReplyFunc(f http.HandlerFunc) *Expectation {
+	this.handler = f
+	return this
+}
+
+// 跳转到 location
+func (this *Expectation) Redirect(status int, location string) *Expectation {
+	return this.ReplyFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, location, status)
+	})
+}
+
+// 等待 d 之后再返回响应头
+func (this *Expectation) Delay(d time.Duration) *Expectation {
+	this.delay = d
+	return this
+}
+
+// 不返回任何响应，直接断开连接
+func (this *Expectation) Reset() *Expectation {
+	this.reset = true
+	return this
+}
+
+// 发送 n 字节的响应体之后断开连接
+func (this *Expectation) ResetAfter(n int) *Expectation {
+	this.resetAfter = n
+	return this
+}
+
+// 响应体每次发送 chunkSize 字节，间隔 interval
+func (this *Expectation) SlowBody(chunkSize int, interval time.Duration) *Expectation {
+	this.chunkSize = chunkSize
+	this.interval = interval
+	return this
+}
+
+func (this *Expectation) String() string {
+	method := this.method
+	if method == "" {
+		method = "*"
+	}
+
+	return method + " " + this.path
+}
+
+// 调用时已经持有 Mock 的锁
+func (this *Expectation) take(r *http.Request, body []byte) bool {
+	if this.times > 0 && this.calls >= this.times {
+		return false
+	}
+	if this.method != "" && this.method != r.Method {
+		return false
+	}
+	if this.path != r.URL.Path {
+		return false
+	}
+	for _, f := range this.matchers {
+		if !f(r, body) {
+			return false
+		}
+	}
+
+	this.calls++
+	return true
+}
+
+func (this *Expectation) verify() error {
+	switch {
+	case this.times > 0 && this.calls != this.times:
+		return fmt.Errorf("%s: expected %d calls, got %d", this, this.times, this.calls)
+	case this.times == 0 && this.calls == 0:
+		return fmt.Errorf("%s: expected at least one call", this)
+	}
+
+	return nil
+}
+
+func (this *Expectation) serve(w http.ResponseWriter, r *http.Request) {
+	if sleep(r.Context(), this.delay) != nil {
+		return
+	}
+	if this.reset {
+		hijackClose(w)
+		return
+	}
+
+	this.handler.ServeHTTP(&slowWriter{
+		ResponseWriter: w,
+		ctx:            r.Context(),
+		remain:         this.resetAfter,
+		chunkSize:      this.chunkSize,
+		interval:       this.interval,
+	}, r)
+}
+
+func (this *Expectation) roundTrip(req *http.Request) (*http.Response, error) {
+	if err := sleep(req.Context(), this.delay); err != nil {
+		return nil, err
+	}
+	if this.reset {
+		return nil, ErrConnectionReset
+	}
+
+	resp := record(req, this.handler)
+	resp.Body = &slowReader{
+		Reader:    resp.Body,
+		ctx:       req.Context(),
+		remain:    this.resetAfter,
+		chunkSize: this.chunkSize,
+		interval:  this.interval,
+	}
+
+	return resp, nil
+}
+
+func replyHandler(status int, body []byte, header http.Header) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(status)
+		if r.Method != "HEAD" {
+			w.Write(body)
+		}
+	})
+}
+
+// 在内存中执行 handler，返回对应的 http.Response
+func record(req *http.Request, handler http.Handler) *http.Response {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	resp := rec.Result()
+	resp.Request = req
+	if resp.ContentLength < 0 {
+		resp.ContentLength = int64(rec.Body.Len())
+	}
+
+	return resp
+}
+
+func hijackClose(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic("gonettest: ResponseWriter does not support Hijack")
+	}
+
+	conn, _, err := hj.Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// 分块慢速写入响应体，写够 remain 字节后断开连接
+type slowWriter struct {
+	http.ResponseWriter
+	ctx       context.Context
+	remain    int
+	chunkSize int
+	interval  time.Duration
+	closed    bool
+}
+
+func (this *slowWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if this.closed {
+			return written, io.ErrClosedPipe
+		}
+
+		n := len(p)
+		if this.chunkSize > 0 && n > this.chunkSize {
+			n = this.chunkSize
+		}
+		if this.remain >= 0 && n > this.remain {
+			n = this.remain
+		}
+
+		m, err := this.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+
+		if this.remain >= 0 {
+			this.remain -= n
+			if this.remain == 0 {
+				this.closed = true
+				// 先把已经写入的数据发出去，Hijack 不会发送 ResponseWriter 中缓冲的数据
+				this.Flush()
+				hijackClose(this.ResponseWriter)
+				return written, io.ErrClosedPipe
+			}
+		}
+
+		if this.chunkSize > 0 && len(p) > 0 {
+			this.Flush()
+			if err := sleep(this.ctx, this.interval); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+func (this *slowWriter) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Transport 模式下的慢速响应体，读够 remain 字节后返回 ErrConnectionReset
+type slowReader struct {
+	io.Reader
+	ctx       context.Context
+	remain    int
+	chunkSize int
+	interval  time.Duration
+	started   bool
+}
+
+func (this *slowReader) Read(p []byte) (int, error) {
+	if this.remain == 0 {
+		return 0, ErrConnectionReset
+	}
+	if this.chunkSize > 0 {
+		if this.started {
+			if err := sleep(this.ctx, this.interval); err != nil {
+				return 0, err
+			}
+		}
+		this.started = true
+		if len(p) > this.chunkSize {
+			p = p[:this.chunkSize]
+		}
+	}
+	if this.remain > 0 && len(p) > this.remain {
+		p = p[:this.remain]
+	}
+
+	n, err := this.Reader.Read(p)
+	if this.remain > 0 {
+		this.remain -= n
+	}
+	if err == nil && this.ctx.Err() != nil {
+		err = this.ctx.Err()
+	}
+
+	return n, err
+}
+
+func (this *slowReader) Close() error {
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,164 @@
+// gonettest 提供测试 gonet 的工具：可以编排的模拟服务器和 http.RoundTripper。
+//
+//	mock := gonettest.NewServer()
+//	defer mock.Close()
+//
+//	mock.On("GET", "/data").Times(2).Reply(503, "busy")
+//	mock.On("GET", "/data").WithHeader("Accept", "*/*").Reply(200, "ok")
+//
+//	data, err := gonet.NewRequest().SetRetries(3).GET(mock.URL + "/data").String()
+//	mock.AssertExpectations(t)
+//
+// 不需要网络时，可以直接使用 NewMock().Transport() 或者 Middleware()
+package gonettest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// TestingT 是 *testing.T 的子集
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Mock 保存所有的预期，按注册的顺序匹配请求，
+// 已经达到次数的预期不再匹配，没有匹配的请求返回 404
+type Mock struct {
+	expectations []*Expectation
+	unmatched    []string
+	lock         *sync.Mutex
+}
+
+func NewMock() *Mock {
+	return &Mock{lock: &sync.Mutex{}}
+}
+
+// 添加一个预期，method 为空或者 * 时匹配所有方法
+func (this *Mock) On(method, path string) *Expectation {
+	e := newExpectation(method, path, this.lock)
+
+	this.lock.Lock()
+	this.expectations = append(this.expectations, e)
+	this.lock.Unlock()
+
+	return e
+}
+
+// 依次从 paths[i] 跳转到 paths[i+1]，最后一个地址的响应需要另外设置
+func (this *Mock) RedirectChain(status int, paths ...string) *Mock {
+	for i := 0; i < len(paths)-1; i++ {
+		this.On("GET", paths[i]).Redirect(status, paths[i+1])
+	}
+
+	return this
+}
+
+// 检查所有的预期都已经满足，并且没有无法匹配的请求
+func (this *Mock) Verify() error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	var errs []string
+	for _, e := range this.expectations {
+		if err := e.verify(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	for _, r := range this.unmatched {
+		errs = append(errs, "unexpected request: "+r)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("gonettest: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (this *Mock) AssertExpectations(t TestingT) bool {
+	t.Helper()
+	if err := this.Verify(); err != nil {
+		t.Errorf("%s", err)
+		return false
+	}
+
+	return true
+}
+
+// 清除所有的预期和记录
+func (this *Mock) Reset() {
+	this.lock.Lock()
+	this.expectations = nil
+	this.unmatched = nil
+	this.lock.Unlock()
+}
+
+func (this *Mock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e, err := this.match(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if e == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	e.serve(w, r)
+}
+
+// 不经过网络，直接返回预期的响应
+func (this *Mock) Transport() http.RoundTripper {
+	return gonet.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		e, err := this.match(req)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return record(req, http.NotFoundHandler()), nil
+		}
+
+		return e.roundTrip(req)
+	})
+}
+
+// 用 Transport 代替 gonet.Request 的网络请求
+func (this *Mock) Middleware() gonet.Middleware {
+	return func(http.RoundTripper) http.RoundTripper {
+		return this.Transport()
+	}
+}
+
+// 找到第一个匹配并且还没有达到次数的预期，请求体会被读出来再放回去
+func (this *Mock) match(r *http.Request) (*Expectation, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, e := range this.expectations {
+		if e.take(r, body) {
+			return e, nil
+		}
+	}
+	this.unmatched = append(this.unmatched, r.Method+" "+r.URL.RequestURI())
+
+	return nil, nil
+}
@@ -0,0 +1,231 @@
+package gonettest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 记录 Errorf 的 TestingT
+type recordT struct {
+	errors []string
+}
+
+func (this *recordT) Helper() {}
+
+func (this *recordT) Errorf(format string, args ...interface{}) {
+	this.errors = append(this.errors, format)
+}
+
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestExpectationOrder(t *testing.T) {
+	mock := NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/data").Times(2).Reply(503, "busy")
+	mock.On("GET", "/data").Reply(200, "ok")
+
+	for i, want := range []int{503, 503, 200, 200} {
+		if code, _ := get(t, mock.Client(), mock.URL+"/data"); code != want {
+			t.Fatalf("request %d: status %d, want %d", i, code, want)
+		}
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestExpectationMatchers(t *testing.T) {
+	mock := NewServer()
+	defer mock.Close()
+
+	json := mock.On("POST", "/items").WithHeader("X-Token", "secret").WithJSON(map[string]int{"id": 1}).ReplyJSON(201, map[string]bool{"ok": true})
+	query := mock.On("*", "/search").WithQuery("q", "go").Reply(200, "found")
+
+	req, _ := http.NewRequest("POST", mock.URL+"/items", strings.NewReader(`{ "id": 1 }`))
+	req.Header.Set("X-Token", "secret")
+	resp, err := mock.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 201 || resp.Header.Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	if code, body := get(t, mock.Client(), mock.URL+"/search?q=go"); code != 200 || body != "found" {
+		t.Fatalf("search: %d %q", code, body)
+	}
+	if code, _ := get(t, mock.Client(), mock.URL+"/search?q=rust"); code != 404 {
+		t.Fatalf("unmatched query: %d", code)
+	}
+
+	if json.Calls() != 1 || query.Calls() != 1 {
+		t.Fatalf("calls = %d, %d", json.Calls(), query.Calls())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	mock := NewMock()
+	mock.On("GET", "/once").Once()
+	mock.On("GET", "/never")
+	mock.On("GET", "/twice").Times(2)
+
+	client := &http.Client{Transport: mock.Transport()}
+	get(t, client, "http://example.com/twice")
+	get(t, client, "http://example.com/unknown")
+
+	err := mock.Verify()
+	if err == nil {
+		t.Fatal("Verify() = nil")
+	}
+	for _, want := range []string{
+		"GET /once: expected 1 calls, got 0",
+		"GET /never: expected at least one call",
+		"GET /twice: expected 2 calls, got 1",
+		"unexpected request: GET /unknown",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Verify() = %q, missing %q", err, want)
+		}
+	}
+
+	rt := &recordT{}
+	if mock.AssertExpectations(rt) || len(rt.errors) != 1 {
+		t.Fatalf("AssertExpectations reported %v", rt.errors)
+	}
+
+	mock.Reset()
+	if err := mock.Verify(); err != nil {
+		t.Fatalf("Verify() after Reset = %v", err)
+	}
+	if code, _ := get(t, client, "http://example.com/twice"); code != 404 {
+		t.Fatalf("status %d after Reset, want 404", code)
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	mock := NewServer()
+	defer mock.Close()
+
+	mock.RedirectChain(http.StatusFound, "/a", "/b", "/c")
+	mock.On("GET", "/c").Reply(200, "done")
+
+	resp, err := mock.Client().Get(mock.URL + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Request.URL.Path != "/c" {
+		t.Fatalf("status %d at %s", resp.StatusCode, resp.Request.URL.Path)
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestSlowBody(t *testing.T) {
+	body := strings.Repeat("x", 40)
+	for _, mode := range []string{"server", "transport"} {
+		t.Run(mode, func(t *testing.T) {
+			mock := NewServer()
+			defer mock.Close()
+			mock.On("GET", "/slow").SlowBody(10, 20*time.Millisecond).Reply(200, body)
+
+			client := mock.Client()
+			url := mock.URL + "/slow"
+			if mode == "transport" {
+				client = &http.Client{Transport: mock.Transport()}
+				url = "http://example.com/slow"
+			}
+
+			start := time.Now()
+			if _, got := get(t, client, url); got != body {
+				t.Fatalf("body = %q", got)
+			}
+			// 4 块之间有 3 次间隔
+			if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+				t.Fatalf("body arrived in %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestDelay(t *testing.T) {
+	mock := NewServer()
+	defer mock.Close()
+	mock.On("GET", "/delay").Delay(time.Second).Reply(200, "late")
+
+	client := mock.Client()
+	client.Timeout = 50 * time.Millisecond
+	if _, err := client.Get(mock.URL + "/delay"); err == nil {
+		t.Fatal("request did not time out")
+	}
+}
+
+func TestReset(t *testing.T) {
+	t.Run("server", func(t *testing.T) {
+		mock := NewServer()
+		defer mock.Close()
+		mock.On("GET", "/reset").Reset()
+
+		if _, err := mock.Client().Get(mock.URL + "/reset"); err == nil {
+			t.Fatal("request succeeded")
+		}
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		mock := NewMock()
+		mock.On("GET", "/reset").Reset()
+
+		client := &http.Client{Transport: mock.Transport()}
+		if _, err := client.Get("http://example.com/reset"); !errors.Is(err, ErrConnectionReset) {
+			t.Fatalf("err = %v, want ErrConnectionReset", err)
+		}
+	})
+}
+
+func TestResetAfter(t *testing.T) {
+	for _, mode := range []string{"server", "transport"} {
+		t.Run(mode, func(t *testing.T) {
+			mock := NewServer()
+			defer mock.Close()
+			mock.On("GET", "/partial").ResetAfter(5).Reply(200, "0123456789")
+
+			client := mock.Client()
+			url := mock.URL + "/partial"
+			if mode == "transport" {
+				client = &http.Client{Transport: mock.Transport()}
+				url = "http://example.com/partial"
+			}
+
+			resp, err := client.Get(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			data, err := ioutil.ReadAll(resp.Body)
+			if err == nil {
+				t.Fatal("body was read without error")
+			}
+			if string(data) != "01234" {
+				t.Fatalf("body = %q, want %q", data, "01234")
+			}
+		})
+	}
+}
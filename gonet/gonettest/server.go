@@ -0,0 +1,21 @@
+package gonettest
+
+import (
+	"net/http/httptest"
+)
+
+// Server 是使用 Mock 处理请求的 httptest.Server
+type Server struct {
+	*httptest.Server
+	*Mock
+}
+
+func NewServer() *Server {
+	mock := NewMock()
+	return &Server{Server: httptest.NewServer(mock), Mock: mock}
+}
+
+func NewTLSServer() *Server {
+	mock := NewMock()
+	return &Server{Server: httptest.NewTLSServer(mock), Mock: mock}
+}
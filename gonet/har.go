@@ -0,0 +1,496 @@
+package gonet
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrHARNotFound = errors.New("gonet: no matching HAR entry")
+
+// HAR 1.2 格式，见 http://www.softwareishard.com/blog/har-12-spec/
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// 不是合法 utf-8 的内容，Text 保存 base64 编码，Encoding 为 base64
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// 单位是毫秒，无法获得的为 -1
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func NewHAR() *HAR {
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "gohelpers/gonet", Version: "1.0"},
+		Entries: []HAREntry{},
+	}}
+}
+
+func LoadHAR(fileName string) (*HAR, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	har := NewHAR()
+	if err := json.Unmarshal(data, har); err != nil {
+		return nil, err
+	}
+
+	return har, nil
+}
+
+func (this *HAR) Save(fileName string) error {
+	data, err := json.MarshalIndent(this, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(fileName, data)
+}
+
+// HARRecorder 记录经过的所有请求和响应。
+// 响应体在读取完成或者关闭时才会记录，所以记录的是解压后的内容
+//
+//	recorder := gonet.NewHARRecorder()
+//	req := gonet.NewRequest().Use(recorder.Middleware())
+//	...
+//	recorder.Save("testdata/site.har")
+type HARRecorder struct {
+	har  *HAR
+	lock *sync.Mutex
+}
+
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{har: NewHAR(), lock: &sync.Mutex{}}
+}
+
+// 返回已经记录的内容的副本
+func (this *HARRecorder) HAR() *HAR {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	har := *this.har
+	har.Log.Entries = append([]HAREntry{}, this.har.Log.Entries...)
+	return &har
+}
+
+func (this *HARRecorder) Save(fileName string) error {
+	return this.HAR().Save(fileName)
+}
+
+func (this *HARRecorder) Reset() {
+	this.lock.Lock()
+	this.har.Log.Entries = []HAREntry{}
+	this.lock.Unlock()
+}
+
+func (this *HARRecorder) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body != nil && req.GetBody == nil {
+				req = req.Clone(req.Context())
+			}
+			postData, err := harPostData(req)
+			if err != nil {
+				return nil, err
+			}
+
+			started := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+			wait := time.Since(started)
+
+			entry := HAREntry{
+				StartedDateTime: started,
+				Request:         harRequest(req, postData),
+				Response:        harResponse(resp),
+				Timings:         HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: harMillis(wait)},
+			}
+
+			resp.Body = &harBody{
+				ReadCloser: resp.Body,
+				finish: func(body []byte) {
+					receive := time.Since(started) - wait
+					entry.Timings.Receive = harMillis(receive)
+					entry.Time = harMillis(wait + receive)
+					entry.Response.Content = harContent(resp.Header.Get("Content-Type"), body)
+					entry.Response.BodySize = len(body)
+
+					this.lock.Lock()
+					this.har.Log.Entries = append(this.har.Log.Entries, entry)
+					this.lock.Unlock()
+				},
+			}
+
+			return resp, nil
+		})
+	}
+}
+
+// 读取完成或者关闭时调用一次 finish
+type harBody struct {
+	io.ReadCloser
+	buf    bytes.Buffer
+	finish func([]byte)
+	once   sync.Once
+}
+
+func (this *harBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	this.buf.Write(p[:n])
+	if err == io.EOF {
+		this.done()
+	}
+
+	return n, err
+}
+
+func (this *harBody) Close() error {
+	err := this.ReadCloser.Close()
+	this.done()
+	return err
+}
+
+func (this *harBody) done() {
+	this.once.Do(func() {
+		this.finish(this.buf.Bytes())
+	})
+}
+
+// HARReplayer 用 HAR 中记录的响应代替网络请求。
+// 按请求方法、URL 和请求体查找记录，同一个请求有多条记录时按顺序返回，
+// 用完之后一直返回最后一条。找不到时返回 ErrHARNotFound
+type HARReplayer struct {
+	entries []HAREntry
+	served  map[string]int
+	lock    *sync.Mutex
+}
+
+func NewHARReplayer(har *HAR) *HARReplayer {
+	return &HARReplayer{
+		entries: har.Log.Entries,
+		served:  make(map[string]int),
+		lock:    &sync.Mutex{},
+	}
+}
+
+func OpenHARReplayer(fileName string) (*HARReplayer, error) {
+	har, err := LoadHAR(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHARReplayer(har), nil
+}
+
+// 不会调用 next，所有请求都从 HAR 中返回
+func (this *HARReplayer) Middleware() Middleware {
+	return func(http.RoundTripper) http.RoundTripper {
+		return this
+	}
+}
+
+func (this *HARReplayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	postData, err := harPostData(req.Clone(req.Context()))
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := this.match(req, postData)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %s", ErrHARNotFound, req.Method, req.URL)
+	}
+
+	return harToResponse(req, entry)
+}
+
+func (this *HARReplayer) match(req *http.Request, postData *HARPostData) (*HAREntry, bool) {
+	text := ""
+	if postData != nil {
+		text = postData.Text
+	}
+
+	var matches []int
+	for i := range this.entries {
+		r := &this.entries[i].Request
+		if !strings.EqualFold(r.Method, req.Method) || r.URL != req.URL.String() {
+			continue
+		}
+		// 记录中没有请求体时，请求也不能有请求体
+		recorded := ""
+		if r.PostData != nil {
+			recorded = r.PostData.Text
+		}
+		if recorded != text {
+			continue
+		}
+		matches = append(matches, i)
+	}
+	if len(matches) == 0 {
+		return nil, false
+	}
+
+	key := req.Method + " " + req.URL.String() + "\n" + text
+
+	this.lock.Lock()
+	n := this.served[key]
+	this.served[key] = n + 1
+	this.lock.Unlock()
+
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+
+	return &this.entries[matches[n]], true
+}
+
+func harToResponse(req *http.Request, entry *HAREntry) (*http.Response, error) {
+	var body []byte
+	content := entry.Response.Content
+	if content.Encoding == "base64" {
+		var err error
+		body, err = base64.StdEncoding.DecodeString(content.Text)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		body = []byte(content.Text)
+	}
+
+	header := http.Header{}
+	for _, h := range entry.Response.Headers {
+		header.Add(h.Name, h.Value)
+	}
+
+	major, minor, ok := http.ParseHTTPVersion(entry.Response.HTTPVersion)
+	if !ok {
+		major, minor = 1, 1
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", entry.Response.Status, entry.Response.StatusText),
+		StatusCode:    entry.Response.Status,
+		Proto:         fmt.Sprintf("HTTP/%d.%d", major, minor),
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// 读取请求体，请求体不能重复读取时会替换 req.Body
+func harPostData(req *http.Request) (*HARPostData, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	} else {
+		body = req.Body
+	}
+
+	data, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if req.GetBody == nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	}
+
+	return &HARPostData{MimeType: req.Header.Get("Content-Type"), Text: string(data)}, nil
+}
+
+func harRequest(req *http.Request, postData *HARPostData) HARRequest {
+	r := HARRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: []HARNameValue{},
+		PostData:    postData,
+		HeadersSize: -1,
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			r.QueryString = append(r.QueryString, HARNameValue{Name: name, Value: value})
+		}
+	}
+	if postData != nil {
+		r.BodySize = len(postData.Text)
+	}
+
+	return r
+}
+
+func harResponse(resp *http.Response) HARResponse {
+	r := HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     harHeaders(resp.Header),
+		RedirectURL: resp.Header.Get("Location"),
+		HeadersSize: -1,
+	}
+	if r.StatusText == "" {
+		r.StatusText = http.StatusText(resp.StatusCode)
+	}
+	if r.HTTPVersion == "" {
+		r.HTTPVersion = "HTTP/1.1"
+	}
+
+	return r
+}
+
+func harContent(mimeType string, body []byte) HARContent {
+	content := HARContent{Size: len(body), MimeType: mimeType}
+	if utf8.Valid(body) {
+		content.Text = string(body)
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString(body)
+		content.Encoding = "base64"
+	}
+
+	return content
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	headers := []HARNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			headers = append(headers, HARNameValue{Name: name, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	ret := []HARCookie{}
+	for _, c := range cookies {
+		cookie := HARCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		ret = append(ret, cookie)
+	}
+
+	return ret
+}
+
+func harMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
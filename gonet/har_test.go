@@ -0,0 +1,124 @@
+package gonet_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+var harBinary = []byte{0x89, 'P', 'N', 'G', 0xff, 0x00, 0xfe}
+
+func newHARServer() *httptest.Server {
+	var lock sync.Mutex
+	hits := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			lock.Lock()
+			hits++
+			n := hits
+			lock.Unlock()
+			w.Write([]byte("a" + strconv.Itoa(n)))
+		case "/login":
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write([]byte("ok " + string(body)))
+		case "/bin":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(harBinary)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func harGet(t *testing.T, req *gonet.Request, method, URL, body string) (string, error) {
+	t.Helper()
+
+	var requestData io.Reader
+	if body != "" {
+		requestData = strings.NewReader(body)
+	}
+	resp, err := req.Do(method, URL, requestData, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := resp.Bytes()
+	return string(data), err
+}
+
+func TestHARRecordReplay(t *testing.T) {
+	server := newHARServer()
+	defer server.Close()
+
+	recorder := gonet.NewHARRecorder()
+	req := gonet.NewRequest().Use(recorder.Middleware())
+	for _, r := range []struct{ method, path, body string }{
+		{"GET", "/a", ""},
+		{"GET", "/a", ""},
+		{"POST", "/login", "user=x"},
+		{"POST", "/login", "user=y"},
+		{"GET", "/bin", ""},
+	} {
+		if _, err := harGet(t, req, r.method, server.URL+r.path, r.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileName := filepath.Join(t.TempDir(), "site.har")
+	if err := recorder.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(recorder.HAR().Log.Entries); n != 5 {
+		t.Fatalf("recorded %d entries, want 5", n)
+	}
+	server.Close()
+
+	replayer, err := gonet.OpenHARReplayer(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = gonet.NewRequest().Use(replayer.Middleware())
+
+	// 同一个请求按记录的顺序返回，用完之后一直返回最后一条
+	for _, want := range []string{"a1", "a2", "a2"} {
+		if body, err := harGet(t, req, "GET", server.URL+"/a", ""); err != nil || body != want {
+			t.Fatalf("GET /a = %q, %v, want %q", body, err, want)
+		}
+	}
+
+	// 按请求体区分
+	for _, user := range []string{"y", "x"} {
+		if body, err := harGet(t, req, "POST", server.URL+"/login", "user="+user); err != nil || body != "ok user="+user {
+			t.Fatalf("POST /login = %q, %v", body, err)
+		}
+	}
+
+	if body, err := harGet(t, req, "GET", server.URL+"/bin", ""); err != nil || !bytes.Equal([]byte(body), harBinary) {
+		t.Fatalf("GET /bin = %q, %v", body, err)
+	}
+
+	notFound := []struct{ method, path, body string }{
+		{"GET", "/missing", ""},
+		{"POST", "/login", "user=z"},
+		{"POST", "/login", ""},
+		// 记录中没有请求体
+		{"POST", "/a", ""},
+		{"GET", "/a", "unexpected"},
+	}
+	for _, r := range notFound {
+		if _, err := harGet(t, req, r.method, server.URL+r.path, r.body); !errors.Is(err, gonet.ErrHARNotFound) {
+			t.Fatalf("%s %s %q: err = %v, want ErrHARNotFound", r.method, r.path, r.body, err)
+		}
+	}
+}
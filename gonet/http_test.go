@@ -0,0 +1,148 @@
+package gonet_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+	"github.com/zhuomouren/gohelpers/gonet/gonettest"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/slow").Delay(2*time.Second).Reply(200, "late")
+
+	start := time.Now()
+	_, err := gonet.NewRequest().SetTimeout(100*time.Millisecond).Do("GET", mock.URL+"/slow", nil, nil, nil)
+	if err == nil {
+		t.Fatal("request did not time out")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("timed out after %v", elapsed)
+	}
+}
+
+func TestRequestTimeoutRetry(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/slow").Once().Delay(2*time.Second).Reply(200, "late")
+	mock.On("GET", "/slow").Once().Reply(200, "ok")
+
+	req := gonet.NewRequest().
+		SetTimeout(100 * time.Millisecond).
+		SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond))
+	resp, err := req.Do("GET", mock.URL+"/slow", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.String(); body != "ok" || resp.Retries() != 1 {
+		t.Fatalf("body = %q, Retries() = %d", body, resp.Retries())
+	}
+}
+
+func TestRequestContextDeadline(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/slow").Delay(2*time.Second).Reply(200, "late")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond))
+	_, err := req.Do("GET", mock.URL+"/slow", nil, nil, ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRequestSlowBodyTimeout(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/slow").SlowBody(1, 100*time.Millisecond).Reply(200, "0123456789")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	resp, err := gonet.NewRequest().Do("GET", mock.URL+"/slow", nil, nil, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resp.Bytes(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+}
+
+func TestRequestMockMiddleware(t *testing.T) {
+	mock := gonettest.NewMock()
+	mock.On("GET", "/data").WithHeader("X-Test", "1").Reply(200, "offline")
+
+	req := gonet.NewRequest().Use(mock.Middleware()).AddHeader("X-Test", "1")
+	body, err := req.GET("http://example.invalid/data").String()
+	if err != nil || body != "offline" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestRequestResultIsolation(t *testing.T) {
+	mock := gonettest.NewMock()
+	mock.On("GET", "/a").Reply(200, "a")
+	mock.On("GET", "/b").Reply(200, "b")
+
+	req := gonet.NewRequest().Use(mock.Middleware())
+	a := req.GET("http://example.invalid/a")
+	b := req.GET("http://example.invalid/b")
+	if body, _ := a.String(); body != "a" {
+		t.Fatalf("a = %q", body)
+	}
+	if body, _ := b.String(); body != "b" {
+		t.Fatalf("b = %q", body)
+	}
+	// GET 不修改 req
+	if _, err := req.String(); !errors.Is(err, gonet.ErrNoResponse) {
+		t.Fatalf("req.String() err = %v, want ErrNoResponse", err)
+	}
+
+	if err := req.Fetch("GET", "http://example.invalid/a", nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := req.String(); body != "a" {
+		t.Fatalf("after Fetch = %q", body)
+	}
+}
+
+func TestRequestCloneConfig(t *testing.T) {
+	var got []string
+	record := func(next http.RoundTripper) http.RoundTripper {
+		return gonet.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			got = append(got, r.Header.Get("X-Clone"))
+			return next.RoundTrip(r)
+		})
+	}
+	mock := gonettest.NewMock()
+	mock.On("GET", "/data").Reply(200, "ok")
+
+	req := gonet.NewRequest().Use(record, mock.Middleware())
+	clone := req.GET("http://example.invalid/data").AddHeader("X-Clone", "1")
+	clone.Use(func(next http.RoundTripper) http.RoundTripper {
+		t.Error("middleware registered on the clone ran on req")
+		return next
+	})
+
+	resp, err := req.Do("GET", "http://example.invalid/data", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if len(got) != 2 || got[1] != "" {
+		t.Fatalf("headers seen by req = %q", got)
+	}
+}
@@ -0,0 +1,118 @@
+package gonet
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/zhuomouren/gohelpers/goip"
+)
+
+// IP 地址的分类和 CIDR 工具，实现在 goip 中。goip 只依赖标准库，不需要 gonet 的包可以直接使用它
+
+var ErrForbiddenAddress = goip.ErrForbiddenAddress
+
+// IPClass 是地址的类别，一个地址可以同时属于多个类别，0 表示公网单播地址
+type IPClass = goip.IPClass
+
+const (
+	IPPrivate       = goip.IPPrivate
+	IPLoopback      = goip.IPLoopback
+	IPLinkLocal     = goip.IPLinkLocal
+	IPCGNAT         = goip.IPCGNAT
+	IPULA           = goip.IPULA
+	IPMulticast     = goip.IPMulticast
+	IPDocumentation = goip.IPDocumentation
+	IPUnspecified   = goip.IPUnspecified
+	IPReserved      = goip.IPReserved
+)
+
+// 一个网卡地址
+type InterfaceAddr = goip.InterfaceAddr
+
+// 地址的类别。IPv4 映射的 IPv6 地址（::ffff:a.b.c.d）按 IPv4 地址判断，
+// NAT64（64:ff9b::/96）和 6to4（2002::/16）地址按其中的 IPv4 地址判断
+func (this *GoNet) ClassifyIP(ip net.IP) IPClass {
+	return goip.Helper.ClassifyIP(ip)
+}
+
+// 是否是私有地址，包括 IPv4 的 10/8、172.16/12、192.168/16 和 IPv6 的 fc00::/7
+func (this *GoNet) IsPrivateIP(ip net.IP) bool {
+	return goip.Helper.IsPrivateIP(ip)
+}
+
+func (this *GoNet) IsLoopbackIP(ip net.IP) bool {
+	return goip.Helper.IsLoopbackIP(ip)
+}
+
+func (this *GoNet) IsLinkLocalIP(ip net.IP) bool {
+	return goip.Helper.IsLinkLocalIP(ip)
+}
+
+func (this *GoNet) IsCGNATIP(ip net.IP) bool {
+	return goip.Helper.IsCGNATIP(ip)
+}
+
+func (this *GoNet) IsULAIP(ip net.IP) bool {
+	return goip.Helper.IsULAIP(ip)
+}
+
+func (this *GoNet) IsMulticastIP(ip net.IP) bool {
+	return goip.Helper.IsMulticastIP(ip)
+}
+
+func (this *GoNet) IsDocumentationIP(ip net.IP) bool {
+	return goip.Helper.IsDocumentationIP(ip)
+}
+
+// 是否是公网单播地址。访问用户提供的 URL 时，可以用来防止 SSRF
+func (this *GoNet) IsPublicIP(ip net.IP) bool {
+	return goip.Helper.IsPublicIP(ip)
+}
+
+// 用作 net.Dialer 的 Control，拒绝连接不是公网地址的 IP，返回 ErrForbiddenAddress。
+// 在建立连接时检查解析后的地址，可以防止 DNS 重绑定：
+//
+//	dialer := &net.Dialer{Control: NetHelper.PublicOnlyControl}
+func (this *GoNet) PublicOnlyControl(network, address string, c syscall.RawConn) error {
+	return goip.Helper.PublicOnlyControl(network, address, c)
+}
+
+// 解析 CIDR，不带前缀长度的 IP 作为 /32 或者 /128。返回的网络地址已经去掉了主机部分
+func (this *GoNet) ParseCIDR(s string) (*net.IPNet, error) {
+	return goip.Helper.ParseCIDR(s)
+}
+
+// 解析多个 CIDR，例如白名单
+func (this *GoNet) ParseCIDRs(list ...string) ([]*net.IPNet, error) {
+	return goip.Helper.ParseCIDRs(list...)
+}
+
+// ip 是否在 nets 中的任意一个网络中
+func (this *GoNet) ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	return goip.Helper.ContainsIP(nets, ip)
+}
+
+// 网络中的第一个和最后一个地址
+func (this *GoNet) CIDRRange(n *net.IPNet) (first, last net.IP) {
+	return goip.Helper.CIDRRange(n)
+}
+
+// 按顺序遍历网络中的每一个地址，f 返回 false 时停止
+func (this *GoNet) EachIP(n *net.IPNet, f func(ip net.IP) bool) {
+	goip.Helper.EachIP(n, f)
+}
+
+// 合并重叠和相邻的网络，返回覆盖相同地址的最少的 CIDR 列表，IPv4 在前
+func (this *GoNet) MergeCIDRs(nets []*net.IPNet) []*net.IPNet {
+	return goip.Helper.MergeCIDRs(nets)
+}
+
+// first 到 last 之间（包括 last）的地址转换成最少的 CIDR 列表，两个地址需要是同一类型
+func (this *GoNet) RangeToCIDRs(first, last net.IP) []*net.IPNet {
+	return goip.Helper.RangeToCIDRs(first, last)
+}
+
+// 本机所有网卡的地址，family 为 ip4 或 ip6 时只返回对应的地址，为空时返回全部
+func (this *GoNet) InterfaceAddrs(family string) ([]InterfaceAddr, error) {
+	return goip.Helper.InterfaceAddrs(family)
+}
@@ -0,0 +1,297 @@
+package gonet
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimiter 按主机限制请求速度和同时进行的请求数。
+// 速度使用令牌桶算法，rate 是每秒请求数，burst 是桶的容量。
+// 收到 429 时速度减半，之后每次成功的请求逐渐恢复；响应中有 Retry-After 时，
+// 在指定的时间之前暂停这个主机的所有请求。
+// 同一个 HostLimiter 可以被多个 Request 共用
+type HostLimiter struct {
+	limit     hostLimit
+	overrides map[string]hostLimit
+	hosts     map[string]*hostState
+	lastSweep time.Time
+	lock      *sync.Mutex
+}
+
+// 超过这个时间没有请求，并且已经恢复到初始状态的主机会被清除
+const hostIdleTimeout = time.Minute
+
+type hostLimit struct {
+	rate     float64
+	burst    int
+	maxConns int
+}
+
+// rate 为 0 表示不限制速度，maxConns 为 0 表示不限制同时进行的请求数
+func NewHostLimiter(rate float64, burst, maxConns int) *HostLimiter {
+	return &HostLimiter{
+		limit:     newHostLimit(rate, burst, maxConns),
+		overrides: make(map[string]hostLimit),
+		hosts:     make(map[string]*hostState),
+		lastSweep: time.Now(),
+		lock:      &sync.Mutex{},
+	}
+}
+
+// 单独设置某个主机的限制，host 不包含端口
+func (this *HostLimiter) SetHostLimit(host string, rate float64, burst, maxConns int) *HostLimiter {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	host = strings.ToLower(host)
+	this.overrides[host] = newHostLimit(rate, burst, maxConns)
+	delete(this.hosts, host)
+	return this
+}
+
+// 等待直到可以向 host 发送请求，请求完成后需要调用返回的 release
+func (this *HostLimiter) Wait(ctx context.Context, host string) (func(), error) {
+	return this.host(host).wait(ctx)
+}
+
+// 根据响应调整 host 的速度
+func (this *HostLimiter) Observe(host string, resp *http.Response) {
+	this.host(host).observe(resp)
+}
+
+// 包装 next，返回限速的 RoundTripper。next 为 nil 时使用 http.DefaultTransport。
+// 响应体读到结尾、读取出错或者被关闭后，才会释放同时请求数的名额
+func (this *HostLimiter) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &limiterTransport{limiter: this, next: next}
+}
+
+func (this *HostLimiter) host(host string) *hostState {
+	host = strings.ToLower(host)
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(this.lastSweep) > hostIdleTimeout {
+		this.lastSweep = now
+		this.sweep(now)
+	}
+
+	state, ok := this.hosts[host]
+	if !ok {
+		limit, ok := this.overrides[host]
+		if !ok {
+			limit = this.limit
+		}
+		state = newHostState(limit)
+		this.hosts[host] = state
+	}
+	state.accessed = now
+
+	return state
+}
+
+// 清除空闲的主机，重新创建的状态和清除前相同。调用时已经持有锁
+func (this *HostLimiter) sweep(now time.Time) {
+	for host, state := range this.hosts {
+		if now.Sub(state.accessed) > hostIdleTimeout && state.idle(now) {
+			delete(this.hosts, host)
+		}
+	}
+}
+
+func newHostLimit(rate float64, burst, maxConns int) hostLimit {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return hostLimit{rate: rate, burst: burst, maxConns: maxConns}
+}
+
+type hostState struct {
+	limit       hostLimit
+	rate        float64 // 当前的速度，收到 429 后会降低
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	conns       chan struct{}
+	lock        *sync.Mutex
+	accessed    time.Time // 最后一次使用的时间，由 HostLimiter 的锁保护
+}
+
+func newHostState(limit hostLimit) *hostState {
+	this := &hostState{
+		limit:  limit,
+		rate:   limit.rate,
+		tokens: float64(limit.burst),
+		last:   time.Now(),
+		lock:   &sync.Mutex{},
+	}
+	if limit.maxConns > 0 {
+		this.conns = make(chan struct{}, limit.maxConns)
+	}
+
+	return this
+}
+
+func (this *hostState) wait(ctx context.Context) (func(), error) {
+	release := func() {}
+	if this.conns != nil {
+		select {
+		case this.conns <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		var once sync.Once
+		release = func() {
+			once.Do(func() { <-this.conns })
+		}
+	}
+
+	if err := sleepContext(ctx, this.reserve()); err != nil {
+		this.cancel()
+		release()
+		return nil, err
+	}
+
+	return release, nil
+}
+
+// 取一个令牌，返回需要等待的时间
+func (this *hostState) reserve() time.Duration {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	if this.rate > 0 {
+		this.tokens += now.Sub(this.last).Seconds() * this.rate
+		if max := float64(this.limit.burst); this.tokens > max {
+			this.tokens = max
+		}
+		this.last = now
+
+		this.tokens--
+		if this.tokens < 0 {
+			wait = time.Duration(-this.tokens / this.rate * float64(time.Second))
+		}
+	}
+
+	if paused := this.pausedUntil.Sub(now); paused > wait {
+		wait = paused
+	}
+
+	return wait
+}
+
+// 没有进行中的请求，没有暂停，速度和令牌都已经恢复
+func (this *hostState) idle(now time.Time) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if len(this.conns) > 0 || now.Before(this.pausedUntil) || this.rate != this.limit.rate {
+		return false
+	}
+	if this.rate > 0 {
+		return this.tokens+now.Sub(this.last).Seconds()*this.rate >= float64(this.limit.burst)
+	}
+
+	return true
+}
+
+// 没有发出请求，归还令牌
+func (this *hostState) cancel() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if this.rate > 0 {
+		this.tokens++
+	}
+}
+
+func (this *hostState) observe(resp *http.Response) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if after, ok := RetryAfter(resp); ok && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if until := time.Now().Add(after); until.After(this.pausedUntil) {
+			this.pausedUntil = until
+		}
+	}
+
+	if this.limit.rate <= 0 {
+		return
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		// 最多降到设置速度的 1/16
+		this.rate = this.rate / 2
+		if min := this.limit.rate / 16; this.rate < min {
+			this.rate = min
+		}
+	} else if resp.StatusCode < 400 && this.rate < this.limit.rate {
+		this.rate += this.limit.rate / 10
+		if this.rate > this.limit.rate {
+			this.rate = this.limit.rate
+		}
+	}
+}
+
+type limiterTransport struct {
+	limiter *HostLimiter
+	next    http.RoundTripper
+}
+
+func (this *limiterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	release, err := this.limiter.Wait(req.Context(), host)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := this.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	this.limiter.Observe(host, resp)
+	if resp.Body == http.NoBody || resp.ContentLength == 0 {
+		release()
+		return resp, nil
+	}
+	// 响应体读取完成或者关闭后，才算请求结束
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+
+	return resp, nil
+}
+
+// 读到结尾、读取出错或者关闭时释放名额，只释放一次
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (this *releaseBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if err != nil {
+		this.once.Do(this.release)
+	}
+	return n, err
+}
+
+func (this *releaseBody) Close() error {
+	err := this.ReadCloser.Close()
+	this.once.Do(this.release)
+	return err
+}
@@ -0,0 +1,89 @@
+package gonet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterReleaseOnEOF(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewHostLimiter(0, 0, 1).Transport(nil)}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		// 读到结尾但不关闭，名额也要释放
+		if _, err := ioutil.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLimiterMaxConns(t *testing.T) {
+	limiter := NewHostLimiter(0, 0, 1)
+	release, err := limiter.Wait(context.Background(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := limiter.Wait(ctx, "example.com"); err != context.DeadlineExceeded {
+		t.Fatalf("Wait = %v, want DeadlineExceeded", err)
+	}
+	if _, err := limiter.Wait(context.Background(), "example.org"); err != nil {
+		t.Fatalf("other host: %v", err)
+	}
+
+	release()
+	release()
+	if _, err := limiter.Wait(context.Background(), "example.com"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	limiter := NewHostLimiter(10, 1, 1)
+
+	release, _ := limiter.Wait(context.Background(), "busy.example.com")
+	done, _ := limiter.Wait(context.Background(), "idle.example.com")
+	done()
+	limiter.Observe("slow.example.com", &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}})
+
+	limiter.lock.Lock()
+	old := time.Now().Add(-2 * hostIdleTimeout)
+	for _, state := range limiter.hosts {
+		state.accessed = old
+		state.last = old
+	}
+	limiter.lastSweep = old
+	limiter.lock.Unlock()
+
+	limiter.host("example.com")
+
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	if _, ok := limiter.hosts["idle.example.com"]; ok {
+		t.Error("idle host was not evicted")
+	}
+	if _, ok := limiter.hosts["busy.example.com"]; !ok {
+		t.Error("host with a request in flight was evicted")
+	}
+	if _, ok := limiter.hosts["slow.example.com"]; !ok {
+		t.Error("host with a reduced rate was evicted")
+	}
+	release()
+}
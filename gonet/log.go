@@ -1,5 +1,10 @@
 package gonet
 
+import (
+	"fmt"
+	"log"
+)
+
 // logging stuff copied from github.com/nsqio/nsq/internal/lg
 
 type LogLevel int
@@ -29,3 +34,8 @@ func (l LogLevel) String() string {
 	}
 	panic("invalid LogLevel")
 }
+
+// 默认的日志，使用标准库的 log 输出
+func defaultLogf(lvl LogLevel, f string, args ...interface{}) {
+	log.Println(fmt.Sprintf(lvl.String()+" "+f, args...))
+}
@@ -0,0 +1,123 @@
+package gonet
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"sync"
+)
+
+// Middleware 包装 http.RoundTripper，可以在请求发出前和收到响应后做处理，
+// 也可以不调用 next，直接返回响应。
+// 修改请求前需要先 Clone，http.RoundTripper 不应该修改传入的请求
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc 把函数转换成 http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// 把多个中间件组合成一个，第一个在最外层
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// 每次发出请求前执行 callbacks，包括重试和跳转
+func OnRequestMiddleware(callbacks ...RequestCallback) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for _, f := range callbacks {
+				f(req)
+			}
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// 输出请求和响应头。通过 Request 发送的请求，记录的内容可以用 Response.Dump 读取。
+// 请求体可以重新读取并且长度已知时，也会输出请求体；流式的请求体不会被读取
+func DumpMiddleware(logf LogFunc) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			dump, err := dumpRequest(req)
+			if err != nil {
+				logf(ERROR, err.Error())
+			}
+
+			logf(DEBUG, "Request:\n%s", string(dump))
+			recordDump(req.Context(), dump)
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+
+			dump, err = httputil.DumpResponse(resp, false)
+			if err != nil {
+				logf(ERROR, err.Error())
+			}
+
+			logf(DEBUG, "Response:\n%s", string(dump))
+			recordDump(req.Context(), dump)
+
+			return resp, nil
+		})
+	}
+}
+
+// 在 req 的副本上 dump，不会读取或者替换 req.Body
+func dumpRequest(req *http.Request) ([]byte, error) {
+	clone := req.Clone(req.Context())
+	withBody := false
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody != nil && req.ContentLength >= 0 {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+		withBody = true
+	}
+
+	return httputil.DumpRequestOut(clone, withBody)
+}
+
+type dumpKey struct{}
+
+// 记录一次 Do 中所有请求和响应的 dump，包括重试和跳转
+type dumpRecorder struct {
+	dumps [][]byte
+	lock  sync.Mutex
+}
+
+func withDumpRecorder(ctx context.Context) (context.Context, *dumpRecorder) {
+	recorder := &dumpRecorder{}
+	return context.WithValue(ctx, dumpKey{}, recorder), recorder
+}
+
+func recordDump(ctx context.Context, dump []byte) {
+	recorder, ok := ctx.Value(dumpKey{}).(*dumpRecorder)
+	if !ok {
+		return
+	}
+
+	recorder.lock.Lock()
+	recorder.dumps = append(recorder.dumps, dump)
+	recorder.lock.Unlock()
+}
+
+func (this *dumpRecorder) Bytes() []byte {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return bytes.Join(this.dumps, []byte("\n"))
+}
@@ -0,0 +1,91 @@
+package gonet
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDumpMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	var dumps []string
+	transport := DumpMiddleware(func(lvl LogLevel, f string, args ...interface{}) {
+		if lvl == DEBUG {
+			dumps = append(dumps, args[0].(string))
+		}
+	})(http.DefaultTransport)
+
+	tests := []struct {
+		name     string
+		body     func() *http.Request
+		dumpBody bool
+	}{
+		{"replayable", func() *http.Request {
+			req, _ := http.NewRequest("POST", srv.URL, strings.NewReader("hello"))
+			return req
+		}, true},
+		{"streamed", func() *http.Request {
+			// 没有 GetBody，只能读取一次
+			req, _ := http.NewRequest("POST", srv.URL, ioutil.NopCloser(strings.NewReader("hello")))
+			return req
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dumps = nil
+			req := tt.body()
+			body := req.Body
+
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if string(data) != "hello" {
+				t.Fatalf("server got %q", data)
+			}
+			if req.Body != body {
+				t.Fatal("request body was replaced")
+			}
+			if len(dumps) != 2 || !strings.HasPrefix(dumps[0], "POST / HTTP/1.1") {
+				t.Fatalf("dumps = %q", dumps)
+			}
+			if got := strings.HasSuffix(dumps[0], "hello"); got != tt.dumpBody {
+				t.Fatalf("request dump = %q", dumps[0])
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order bytes.Buffer
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order.WriteString(name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	final := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		order.WriteString("!")
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	Chain(mark("a"), mark("b"), mark("c"))(final).RoundTrip(req)
+
+	if order.String() != "abc!" {
+		t.Fatalf("order = %q", order.String())
+	}
+}
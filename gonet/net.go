@@ -1,10 +1,16 @@
 package gonet
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/zhuomouren/gohelpers/goip"
 )
 
 type GoNet struct{}
@@ -25,37 +31,254 @@ func (this *GoNet) Ping(network, address string, timeout time.Duration) error {
 	return err
 }
 
-// 检查端口是否可用
+// 检查本机是否可以监听 TCP 端口
 func (this *GoNet) PortIsAvailable(port int) bool {
-	conn, err := net.DialTimeout("tcp", fmt.Sprintf("0.0.0.0:%d", port), time.Second)
-	if conn != nil {
-		defer conn.Close()
+	return this.CanListen("tcp", fmt.Sprintf(":%d", port)) == nil
+}
+
+// 尝试监听 address 后立即关闭，返回监听时的错误。network 可以是 tcp、tcp4、tcp6、udp、udp4、udp6 和 unix
+// err := CanListen("tcp", "127.0.0.1:8088")
+func (this *GoNet) CanListen(network, address string) error {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
 	}
 
-	return err == nil
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	return ln.Close()
 }
 
-func (this *GoNet) PrivateIPv4() (net.IP, error) {
-	as, err := net.InterfaceAddrs()
+// 由系统分配一个本机可以监听的 TCP 端口。端口在返回后才会被使用，期间可能被其他程序占用
+func (this *GoNet) FreePort() (int, error) {
+	ports, err := this.FreePorts(1)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	for _, a := range as {
-		ipnet, ok := a.(*net.IPNet)
-		if !ok || ipnet.IP.IsLoopback() {
-			continue
+	return ports[0], nil
+}
+
+// 分配 n 个不重复的端口
+func (this *GoNet) FreePorts(n int) ([]int, error) {
+	ports := make([]int, 0, n)
+	listeners := make([]net.Listener, 0, n)
+	defer func() {
+		for _, ln := range listeners {
+			ln.Close()
 		}
+	}()
 
-		ip := ipnet.IP.To4()
-		if this.IsPrivateIPv4(ip) {
-			return ip, nil
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			return nil, err
 		}
+		listeners = append(listeners, ln)
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
 	}
-	return nil, errors.New("no private ip address")
+
+	return ports, nil
 }
 
+// 本机网卡上的第一个 IPv4 私有地址
+func (this *GoNet) PrivateIPv4() (net.IP, error) {
+	return goip.Helper.PrivateIPv4()
+}
+
+// 是否是 IPv4 的私有地址，即 10/8、172.16/12 和 192.168/16
 func (this *GoNet) IsPrivateIPv4(ip net.IP) bool {
-	return ip != nil &&
-		(ip[0] == 10 || ip[0] == 172 && (ip[1] >= 16 && ip[1] < 32) || ip[0] == 192 && ip[1] == 168)
+	return goip.Helper.IsPrivateIPv4(ip)
+}
+
+// 一个端口的扫描结果
+type PortResult struct {
+	Port    int
+	Open    bool
+	Latency time.Duration // 建立连接的耗时，端口没有打开时为 0
+	Err     error         // 连接失败的原因
+}
+
+// 生成 from 到 to 的端口列表，包括 to
+func (this *GoNet) PortRange(from, to int) []int {
+	var ports []int
+	for port := from; port <= to; port++ {
+		ports = append(ports, port)
+	}
+
+	return ports
+}
+
+// 用最多 workers 个 goroutine 同时连接 host 的 TCP 端口，每个连接的超时时间是 timeout。
+// 结果按端口排序，ctx 被取消时没有扫描的端口不会出现在结果中
+//
+//	results := NetHelper.ScanPorts(ctx, "192.168.1.1", NetHelper.PortRange(1, 1024), 100, time.Second)
+func (this *GoNet) ScanPorts(ctx context.Context, host string, ports []int, workers int, timeout time.Duration) []PortResult {
+	if workers <= 0 {
+		workers = 1
+	}
+	if workers > len(ports) {
+		workers = len(ports)
+	}
+
+	jobs := make(chan int)
+	results := make(chan PortResult)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for port := range jobs {
+				results <- this.scanPort(ctx, host, port, timeout)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, port := range ports {
+			select {
+			case jobs <- port:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var list []PortResult
+	for result := range results {
+		// ctx 被取消导致的失败不是端口的状态
+		if !result.Open && ctx.Err() != nil {
+			continue
+		}
+		list = append(list, result)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Port < list[j].Port
+	})
+
+	return list
+}
+
+// 只返回打开的端口
+func (this *GoNet) OpenPorts(ctx context.Context, host string, ports []int, workers int, timeout time.Duration) []int {
+	var open []int
+	for _, result := range this.ScanPorts(ctx, host, ports, workers, timeout) {
+		if result.Open {
+			open = append(open, result.Port)
+		}
+	}
+
+	return open
+}
+
+func (this *GoNet) scanPort(ctx context.Context, host string, port int, timeout time.Duration) PortResult {
+	result := PortResult{Port: port}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	conn.Close()
+
+	result.Open = true
+	result.Latency = time.Since(start)
+	return result
+}
+
+// 一次 DNS 查询的结果
+type DNSResult struct {
+	Name    string
+	Type    string // A、AAAA、CNAME、MX、NS、TXT 或者 PTR
+	Server  string // 为空表示使用系统的 DNS 服务器
+	Records []string
+	Latency time.Duration
+}
+
+// 使用 server 作为 DNS 服务器的 Resolver，server 可以不带端口，默认是 53。
+// server 为空时返回系统默认的 Resolver
+func (this *GoNet) NewResolver(server string) *net.Resolver {
+	if server == "" {
+		return net.DefaultResolver
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, server)
+		},
+	}
+}
+
+// 查询 name 的 recordType 记录，server 为空时使用系统的 DNS 服务器。
+// MX 记录的格式是 "优先级 主机名"，PTR 查询时 name 是 IP 地址
+//
+//	result, err := NetHelper.LookupDNS(ctx, "example.com", "MX", "8.8.8.8")
+func (this *GoNet) LookupDNS(ctx context.Context, name, recordType, server string) (*DNSResult, error) {
+	resolver := this.NewResolver(server)
+	result := &DNSResult{Name: name, Type: strings.ToUpper(recordType), Server: server}
+
+	start := time.Now()
+	var err error
+	switch result.Type {
+	case "A", "AAAA":
+		network := "ip4"
+		if result.Type == "AAAA" {
+			network = "ip6"
+		}
+		var ips []net.IP
+		if ips, err = resolver.LookupIP(ctx, network, name); err == nil {
+			for _, ip := range ips {
+				result.Records = append(result.Records, ip.String())
+			}
+		}
+	case "CNAME":
+		var cname string
+		if cname, err = resolver.LookupCNAME(ctx, name); err == nil {
+			result.Records = []string{cname}
+		}
+	case "MX":
+		var mxs []*net.MX
+		if mxs, err = resolver.LookupMX(ctx, name); err == nil {
+			for _, mx := range mxs {
+				result.Records = append(result.Records, fmt.Sprintf("%d %s", mx.Pref, mx.Host))
+			}
+		}
+	case "NS":
+		var nss []*net.NS
+		if nss, err = resolver.LookupNS(ctx, name); err == nil {
+			for _, ns := range nss {
+				result.Records = append(result.Records, ns.Host)
+			}
+		}
+	case "TXT":
+		result.Records, err = resolver.LookupTXT(ctx, name)
+	case "PTR":
+		result.Records, err = resolver.LookupAddr(ctx, name)
+	default:
+		return nil, fmt.Errorf("gonet: unsupported dns record type %s", recordType)
+	}
+	result.Latency = time.Since(start)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
@@ -0,0 +1,305 @@
+package gonet
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// 常见的跟踪参数和会话 ID，* 结尾表示前缀匹配，不区分大小写。
+// 只包含不会用来区分页面内容的参数，例如 sid 在很多网站上是栏目或者文章的 ID，不在这里
+var TrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "msclkid", "yclid", "mc_cid", "mc_eid", "_ga", "_gl",
+	"jsessionid", "phpsessid", "aspsessionid*", "sessionid",
+}
+
+// NormalizeOptions 决定 Normalize 执行哪些步骤，见 RFC 3986 第 6 节
+type NormalizeOptions struct {
+	LowercaseHost     bool     // scheme 和主机名转换成小写，scheme 总是小写
+	RemoveDefaultPort bool     // 去掉 http:80、https:443 等默认端口
+	RemoveDotSegments bool     // 处理路径中的 . 和 ..，空路径改成 /
+	NormalizeEscapes  bool     // 解码不需要编码的字符（字母、数字和 -._~），其他编码使用大写十六进制
+	SortQuery         bool     // 按参数名排序查询参数，同名参数保持原来的顺序
+	RemoveFragment    bool     // 去掉 # 之后的部分
+	IDNA              bool     // 国际化域名转换成 punycode
+	StripParams       []string // 去掉的查询参数，* 结尾表示前缀匹配，不区分大小写。也会去掉路径中的 ;jsessionid=
+}
+
+// Normalize 默认执行所有步骤，去掉 TrackingParams 中的参数
+var DefaultNormalizeOptions = NormalizeOptions{
+	LowercaseHost:     true,
+	RemoveDefaultPort: true,
+	RemoveDotSegments: true,
+	NormalizeEscapes:  true,
+	SortQuery:         true,
+	RemoveFragment:    true,
+	IDNA:              true,
+	StripParams:       TrackingParams,
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+	"ftp":   "21",
+}
+
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// 规范化 URL，等价的 URL 得到相同的结果，可以用来给 URL 去重：
+//
+//	HTTP://Example.COM:80/a/./b/../c?b=2&a=1&utm_source=x#top => http://example.com/a/c?a=1&b=2
+func (this *GoURL) Normalize(rawurl string) (string, error) {
+	return this.NormalizeWith(rawurl, DefaultNormalizeOptions)
+}
+
+func (this *GoURL) NormalizeWith(rawurl string, opts NormalizeOptions) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawurl))
+	if err != nil {
+		return "", err
+	}
+	if u.Opaque != "" {
+		if opts.RemoveFragment {
+			u.Fragment, u.RawFragment = "", ""
+		}
+		return u.String(), nil
+	}
+
+	host := u.Host
+	if opts.LowercaseHost {
+		host = strings.ToLower(host)
+	}
+	if opts.IDNA && host != "" {
+		hostname, port := u.Hostname(), u.Port()
+		if !strings.Contains(hostname, ":") {
+			if hostname, err = idnaProfile.ToASCII(hostname); err != nil {
+				return "", err
+			}
+			host = hostname
+			if port != "" {
+				host += ":" + port
+			}
+		}
+	}
+	if opts.RemoveDefaultPort {
+		if port := defaultPorts[u.Scheme]; port != "" {
+			host = strings.TrimSuffix(host, ":"+port)
+		}
+	}
+
+	p := u.EscapedPath()
+	if len(opts.StripParams) > 0 {
+		p = stripPathParams(p, opts.StripParams)
+	}
+	if opts.NormalizeEscapes {
+		p = normalizeEscapes(p)
+	}
+	if opts.RemoveDotSegments {
+		p = removeDotSegments(p)
+		if p == "" && host != "" {
+			p = "/"
+		}
+	}
+
+	query := u.RawQuery
+	if len(opts.StripParams) > 0 || opts.SortQuery || opts.NormalizeEscapes {
+		query = normalizeQuery(query, opts)
+	}
+
+	var b strings.Builder
+	if u.Scheme != "" {
+		b.WriteString(u.Scheme + ":")
+	}
+	if u.Scheme != "" || host != "" || u.User != nil {
+		b.WriteString("//")
+		if u.User != nil {
+			b.WriteString(u.User.String() + "@")
+		}
+		b.WriteString(host)
+	}
+	b.WriteString(p)
+	if query != "" {
+		b.WriteString("?" + query)
+	}
+	if !opts.RemoveFragment && u.Fragment != "" {
+		fragment := u.EscapedFragment()
+		if opts.NormalizeEscapes {
+			fragment = normalizeEscapes(fragment)
+		}
+		b.WriteString("#" + fragment)
+	}
+
+	return b.String(), nil
+}
+
+// 是否 RFC 3986 中的非保留字符
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// 解码非保留字符，其他编码改成大写；控制字符、空格和非 ASCII 字符进行编码
+func normalizeEscapes(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			v := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(v) {
+				b.WriteByte(v)
+			} else {
+				b.WriteByte('%')
+				b.WriteByte(hex[v>>4])
+				b.WriteByte(hex[v&15])
+			}
+			i += 2
+		case c <= 0x20 || c >= 0x7f || strings.IndexByte("\"<>\\^`{|}", c) >= 0:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+// RFC 3986 5.2.4 remove_dot_segments，不会合并连续的斜杠
+func removeDotSegments(p string) string {
+	if p == "" {
+		return ""
+	}
+
+	var out []string
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			// 第一段是开头斜杠之前的空字符串，不能去掉
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, segment)
+		}
+	}
+
+	result := strings.Join(out, "/")
+	if strings.HasPrefix(p, "/") && !strings.HasPrefix(result, "/") {
+		result = "/" + result
+	}
+
+	return result
+}
+
+// 去掉路径中 ;name=value 形式的会话参数，例如 /a;jsessionid=123
+func stripPathParams(p string, params []string) string {
+	if strings.IndexByte(p, ';') < 0 {
+		return p
+	}
+
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		parts := strings.Split(segment, ";")
+		kept := parts[:1]
+		for _, param := range parts[1:] {
+			name := param
+			if j := strings.IndexByte(param, '='); j >= 0 {
+				name = param[:j]
+			}
+			if !matchParam(name, params) {
+				kept = append(kept, param)
+			}
+		}
+		segments[i] = strings.Join(kept, ";")
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func normalizeQuery(query string, opts NormalizeOptions) string {
+	type pair struct {
+		key, raw string
+	}
+
+	var pairs []pair
+	for _, raw := range strings.Split(query, "&") {
+		if raw == "" {
+			continue
+		}
+
+		key := raw
+		if i := strings.IndexByte(raw, '='); i >= 0 {
+			key = raw[:i]
+		}
+		if decoded, err := url.QueryUnescape(key); err == nil {
+			key = decoded
+		}
+		if matchParam(key, opts.StripParams) {
+			continue
+		}
+
+		if opts.NormalizeEscapes {
+			raw = normalizeEscapes(raw)
+		}
+		pairs = append(pairs, pair{key: key, raw: raw})
+	}
+
+	if opts.SortQuery {
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return pairs[i].key < pairs[j].key
+		})
+	}
+
+	raws := make([]string, len(pairs))
+	for i, p := range pairs {
+		raws[i] = p.raw
+	}
+
+	return strings.Join(raws, "&")
+}
+
+func matchParam(name string, params []string) bool {
+	name = strings.ToLower(name)
+	for _, param := range params {
+		param = strings.ToLower(param)
+		if strings.HasSuffix(param, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(param, "*")) {
+				return true
+			}
+		} else if name == param {
+			return true
+		}
+	}
+
+	return false
+}
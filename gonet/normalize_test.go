@@ -0,0 +1,66 @@
+package gonet
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"HTTP://Example.COM:80/a/./b/../c?b=2&a=1&utm_source=x#top", "http://example.com/a/c?a=1&b=2"},
+		{"https://example.com:443", "https://example.com/"},
+		{"https://example.com:8443/%7euser/%2fx%3a?q=%e4%b8%ad", "https://example.com:8443/~user/%2Fx%3A?q=%E4%B8%AD"},
+		{"http://例子.测试/路径?a=b c", "http://xn--fsqu00a.xn--0zwm56d/%E8%B7%AF%E5%BE%84?a=b%20c"},
+		{"http://a.com/x;jsessionid=ABC?PHPSESSID=1&z=1&a=2&a=1", "http://a.com/x?a=2&a=1&z=1"},
+		{"http://a.com/story?sid=42&fbclid=x", "http://a.com/story?sid=42"},
+		{"http://a.com/../../x/..", "http://a.com/"},
+		{"http://a.com//double//slash/", "http://a.com//double//slash/"},
+		{"http://user:pw@[::1]:80/p", "http://user:pw@[::1]/p"},
+		{"mailto:x@y.com", "mailto:x@y.com"},
+		{"http://foo_bar.example.com/", "http://foo_bar.example.com/"},
+	}
+	for _, tt := range tests {
+		got, err := URLHelper.Normalize(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Normalize(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestNormalizeWith(t *testing.T) {
+	got, err := URLHelper.NormalizeWith("HTTP://A.com/b?z=1&a=2#f", NormalizeOptions{})
+	if err != nil || got != "http://A.com/b?z=1&a=2#f" {
+		t.Fatalf("no options: %q, %v", got, err)
+	}
+
+	opts := DefaultNormalizeOptions
+	opts.StripParams = []string{"ref*"}
+	got, err = URLHelper.NormalizeWith("http://a.com/?referrer=x&ref=y&utm_source=z", opts)
+	if err != nil || got != "http://a.com/?utm_source=z" {
+		t.Fatalf("custom params: %q, %v", got, err)
+	}
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	tests := []struct {
+		ref, base, want string
+	}{
+		{"c.html", "http://a.com/b/index.html?x=1", "http://a.com/b/c.html"},
+		{"c", "http://a.com/b", "http://a.com/c"},
+		{"c", "http://a.com/b/", "http://a.com/b/c"},
+		{"?q=1", "http://a.com/b/d.html?x=1", "http://a.com/b/d.html?q=1"},
+		{"//cdn.com/x.js", "https://a.com/", "https://cdn.com/x.js"},
+		{"../x?y=1", "http://a.com/b/c/d", "http://a.com/b/x?y=1"},
+		{"/p//q", "http://a.com/b", "http://a.com/p//q"},
+		{"http://z.com/x", "http://a.com/b", "http://z.com/x"},
+	}
+	for _, tt := range tests {
+		got, err := URLHelper.AbsoluteURL(tt.ref, tt.base)
+		if err != nil || got != tt.want {
+			t.Errorf("AbsoluteURL(%q, %q) = %q, %v, want %q", tt.ref, tt.base, got, err, tt.want)
+		}
+	}
+	if _, err := URLHelper.AbsoluteURL("#x", "http://a.com"); err == nil {
+		t.Error("AbsoluteURL(#x) returned no error")
+	}
+}
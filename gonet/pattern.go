@@ -0,0 +1,387 @@
+package gonet
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var ErrInvalidPattern = errors.New("gonet: invalid url pattern")
+
+// URLPattern 是编译好的 URL 规则，可以在多个 goroutine 中使用。
+//
+// glob 写法是 [scheme://]host[:port][/path][?query]，例如：
+//
+//	*.example.com/book/:id/*.html
+//	https://www.example.com/list/**
+//
+// 没有 scheme 时匹配 http 和 https，scheme 为 * 时匹配所有。
+// host 为 * 时匹配所有主机，*.example.com 匹配 example.com 和它的所有子域名，不区分大小写。
+// 没有端口时匹配所有端口。
+// path 中 :name 匹配一段路径并保存为 name，* 匹配一段路径中的任意字符，** 匹配任意字符，包括 /。
+// 没有 path 时匹配所有路径；没有 ? 时忽略查询参数，否则查询参数也要匹配，写法和 path 相同。
+// # 之后的部分总是忽略。
+//
+// 以 re: 开头的是正则，不区分大小写，需要匹配整个 URL，(?P<name>...) 保存为 name：
+//
+//	re:https?://www\.example\.com/book/(?P<id>\d+)\.html
+type URLPattern struct {
+	raw    string
+	re     *regexp.Regexp // 正则规则
+	scheme string         // 空表示 http 和 https，* 表示所有
+	host   string         // 空表示所有主机
+	suffix bool           // host 是否以 *. 开头
+	port   string
+	path   *regexp.Regexp // nil 表示所有路径
+	query  *regexp.Regexp // nil 表示忽略查询参数
+}
+
+func CompileURLPattern(pattern string) (*URLPattern, error) {
+	this := &URLPattern{raw: pattern}
+
+	if strings.HasPrefix(pattern, "re:") {
+		re, err := regexp.Compile(`(?i)^(?:` + strings.TrimPrefix(pattern, "re:") + `)$`)
+		if err != nil {
+			return nil, err
+		}
+		this.re = re
+		return this, nil
+	}
+
+	rest := pattern
+	if i := strings.Index(rest, "://"); i >= 0 {
+		this.scheme, rest = strings.ToLower(rest[:i]), rest[i+3:]
+	}
+
+	var query string
+	hasQuery := false
+	if i := strings.IndexByte(rest, '?'); i >= 0 {
+		rest, query, hasQuery = rest[:i], rest[i+1:], true
+	}
+
+	authority, path := rest, ""
+	if i := strings.IndexByte(rest, '/'); i >= 0 {
+		authority, path = rest[:i], rest[i:]
+	}
+
+	host := strings.ToLower(authority)
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.HasSuffix(host, "]") {
+		host, this.port = host[:i], host[i+1:]
+	}
+	host = strings.Trim(host, "[]")
+	switch {
+	case host == "" || host == "*":
+	case strings.HasPrefix(host, "*."):
+		this.host, this.suffix = strings.TrimPrefix(host, "*."), true
+	case strings.Contains(host, "*"):
+		return nil, ErrInvalidPattern
+	default:
+		this.host = host
+	}
+
+	var err error
+	if path != "" {
+		if this.path, err = compileGlob(path); err != nil {
+			return nil, err
+		}
+	}
+	if hasQuery {
+		if this.query, err = compileGlob(query); err != nil {
+			return nil, err
+		}
+	}
+
+	return this, nil
+}
+
+func MustCompileURLPattern(pattern string) *URLPattern {
+	p, err := CompileURLPattern(pattern)
+	if err != nil {
+		panic(`gonet: CompileURLPattern(` + pattern + `): ` + err.Error())
+	}
+
+	return p
+}
+
+// 把 glob 转换成正则，:name 转换成命名分组
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == ':' && i+1 < len(glob) && isNameChar(glob[i+1]):
+			j := i + 1
+			for j < len(glob) && isNameChar(glob[j]) {
+				j++
+			}
+			b.WriteString("(?P<" + glob[i+1:j] + ">[^/]+)")
+			i = j - 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return regexp.Compile(b.String())
+}
+
+func isNameChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
+func (this *URLPattern) String() string {
+	return this.raw
+}
+
+func (this *URLPattern) Match(rawurl string) bool {
+	_, ok := this.Params(rawurl)
+	return ok
+}
+
+// 匹配时返回命名的参数，没有参数时返回空的 map
+func (this *URLPattern) Params(rawurl string) (map[string]string, bool) {
+	if this.re != nil {
+		return this.match(rawurl, nil)
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, false
+	}
+	return this.match(rawurl, u)
+}
+
+// u 为 nil 时只能匹配正则规则
+func (this *URLPattern) match(rawurl string, u *url.URL) (map[string]string, bool) {
+	params := map[string]string{}
+
+	if this.re != nil {
+		m := this.re.FindStringSubmatch(rawurl)
+		if m == nil {
+			return nil, false
+		}
+		addParams(params, this.re, m)
+		return params, true
+	}
+	if u == nil {
+		return nil, false
+	}
+
+	switch this.scheme {
+	case "*":
+	case "":
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, false
+		}
+	default:
+		if u.Scheme != this.scheme {
+			return nil, false
+		}
+	}
+
+	if this.host != "" && !this.matchHost(strings.ToLower(u.Hostname())) {
+		return nil, false
+	}
+	if this.port != "" && u.Port() != this.port {
+		return nil, false
+	}
+
+	if this.path != nil {
+		path := u.EscapedPath()
+		if path == "" {
+			path = "/"
+		}
+		m := this.path.FindStringSubmatch(path)
+		if m == nil {
+			return nil, false
+		}
+		addParams(params, this.path, m)
+	}
+	if this.query != nil {
+		m := this.query.FindStringSubmatch(u.RawQuery)
+		if m == nil {
+			return nil, false
+		}
+		addParams(params, this.query, m)
+	}
+
+	return params, true
+}
+
+func (this *URLPattern) matchHost(host string) bool {
+	if this.suffix {
+		return host == this.host || strings.HasSuffix(host, "."+this.host)
+	}
+
+	return host == this.host
+}
+
+func addParams(params map[string]string, re *regexp.Regexp, m []string) {
+	for i, name := range re.SubexpNames() {
+		if name == "" {
+			continue
+		}
+		value := m[i]
+		if unescaped, err := url.PathUnescape(value); err == nil {
+			value = unescaped
+		}
+		params[name] = value
+	}
+}
+
+// URLPatternSet 按主机名索引多个 URLPattern，匹配一个 URL 时只检查可能匹配的规则。
+// 匹配结果按添加的顺序返回
+type URLPatternSet struct {
+	hosts    map[string][]*indexedPattern // 精确的主机名
+	suffixes map[string][]*indexedPattern // *.example.com 中的 example.com
+	others   []*indexedPattern            // 正则和匹配所有主机的规则
+	count    int
+	lock     *sync.RWMutex
+}
+
+type indexedPattern struct {
+	*URLPattern
+	index int
+}
+
+func NewURLPatternSet() *URLPatternSet {
+	return &URLPatternSet{
+		hosts:    make(map[string][]*indexedPattern),
+		suffixes: make(map[string][]*indexedPattern),
+		lock:     &sync.RWMutex{},
+	}
+}
+
+// 编译并添加规则
+func (this *URLPatternSet) Add(patterns ...string) error {
+	for _, pattern := range patterns {
+		p, err := CompileURLPattern(pattern)
+		if err != nil {
+			return err
+		}
+		this.AddPattern(p)
+	}
+
+	return nil
+}
+
+func (this *URLPatternSet) AddPattern(p *URLPattern) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	entry := &indexedPattern{URLPattern: p, index: this.count}
+	this.count++
+
+	switch {
+	case p.host == "":
+		this.others = append(this.others, entry)
+	case p.suffix:
+		this.suffixes[p.host] = append(this.suffixes[p.host], entry)
+	default:
+		this.hosts[p.host] = append(this.hosts[p.host], entry)
+	}
+}
+
+func (this *URLPatternSet) Len() int {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	return this.count
+}
+
+// 是否有规则匹配
+func (this *URLPatternSet) Match(rawurl string) bool {
+	_, _, ok := this.Find(rawurl)
+	return ok
+}
+
+// 第一个匹配的规则和它的参数
+func (this *URLPatternSet) Find(rawurl string) (*URLPattern, map[string]string, bool) {
+	u, candidates := this.candidates(rawurl)
+	for _, p := range candidates {
+		if params, ok := p.match(rawurl, u); ok {
+			return p.URLPattern, params, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// 所有匹配的规则
+func (this *URLPatternSet) FindAll(rawurl string) []*URLPattern {
+	var patterns []*URLPattern
+	u, candidates := this.candidates(rawurl)
+	for _, p := range candidates {
+		if _, ok := p.match(rawurl, u); ok {
+			patterns = append(patterns, p.URLPattern)
+		}
+	}
+
+	return patterns
+}
+
+// 可能匹配 rawurl 的规则，按添加的顺序排列。
+// 每个列表本身已经是按添加顺序排列的，只需要合并
+func (this *URLPatternSet) candidates(rawurl string) (*url.URL, []*indexedPattern) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		u = nil
+	}
+
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+
+	lists := [][]*indexedPattern{this.others}
+	if u != nil {
+		host := strings.ToLower(u.Hostname())
+		lists = append(lists, this.hosts[host])
+		for {
+			lists = append(lists, this.suffixes[host])
+			i := strings.IndexByte(host, '.')
+			if i < 0 {
+				break
+			}
+			host = host[i+1:]
+		}
+	}
+
+	return u, mergePatterns(lists)
+}
+
+// 合并按 index 排序的多个列表。只有一个列表不为空时直接返回它，调用者不能修改返回的列表
+func mergePatterns(lists [][]*indexedPattern) []*indexedPattern {
+	n, nonEmpty := 0, 0
+	var last []*indexedPattern
+	for _, list := range lists {
+		if len(list) > 0 {
+			n += len(list)
+			nonEmpty++
+			last = list
+		}
+	}
+	if nonEmpty <= 1 {
+		return last
+	}
+
+	merged := make([]*indexedPattern, 0, n)
+	for len(merged) < n {
+		min := -1
+		for i, list := range lists {
+			if len(list) > 0 && (min < 0 || list[0].index < lists[min][0].index) {
+				min = i
+			}
+		}
+		merged = append(merged, lists[min][0])
+		lists[min] = lists[min][1:]
+	}
+
+	return merged
+}
@@ -0,0 +1,109 @@
+package gonet
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestURLPattern(t *testing.T) {
+	p := MustCompileURLPattern("*.example.com/book/:id/*.html")
+	params, ok := p.Params("https://M.Example.com/book/12%2034/ch1.html?x=1#f")
+	if !ok || params["id"] != "12 34" {
+		t.Fatalf("Params = %v, %v", params, ok)
+	}
+
+	tests := []struct {
+		pattern string
+		url     string
+		want    bool
+	}{
+		{"*.example.com/book/:id/*.html", "http://example.com/book/1/a.html", true},
+		{"*.example.com/book/:id/*.html", "http://badexample.com/book/1/a.html", false},
+		{"*.example.com/book/:id/*.html", "ftp://a.example.com/book/1/a.html", false},
+		{"*.example.com/book/:id/*.html", "http://a.example.com/book/1/2/a.html", false},
+		{"*.example.com/book/:id/*.html", "http://a.example.com/book//a.html", false},
+		{"*.example.com/book/:id/*.html", "http://a.example.com/book/1/a.htm", false},
+		{"https://www.example.com:8080/list/**", "https://www.example.com:8080/list/a/b/c", true},
+		{"https://www.example.com:8080/list/**", "https://www.example.com/list/a", false},
+		{"example.com", "http://example.com/any/thing?q", true},
+		{"*/search?q=*", "http://x.y/search", false},
+		{"*://[::1]:80/**", "ws://[::1]:80/a", true},
+		{`re:https?://www\.example\.com/book/\d+\.html`, "HTTP://WWW.example.com/book/42.html", true},
+		// 正则需要匹配整个 URL
+		{`re:https?://www\.example\.com/book/\d+\.html`, "http://www.example.com/book/42.html?x", false},
+	}
+	for _, tt := range tests {
+		if got := MustCompileURLPattern(tt.pattern).Match(tt.url); got != tt.want {
+			t.Errorf("%s Match(%s) = %v, want %v", tt.pattern, tt.url, got, tt.want)
+		}
+	}
+
+	if params, ok := MustCompileURLPattern("*/search?q=:kw").Params("http://x.y/search?q=go"); !ok || params["kw"] != "go" {
+		t.Errorf("query params = %v, %v", params, ok)
+	}
+	if params, ok := MustCompileURLPattern(`re:.*/book/(?P<id>\d+)\.html`).Params("http://a.com/book/42.html"); !ok || params["id"] != "42" {
+		t.Errorf("regexp params = %v, %v", params, ok)
+	}
+
+	for _, pattern := range []string{"a*b.com/x", "re:("} {
+		if _, err := CompileURLPattern(pattern); err == nil {
+			t.Errorf("CompileURLPattern(%q) returned no error", pattern)
+		}
+	}
+}
+
+func TestURLPatternSet(t *testing.T) {
+	set := NewURLPatternSet()
+	for i := 0; i < 1000; i++ {
+		set.Add(fmt.Sprintf("www.site%d.com/book/:id.html", i))
+	}
+	set.Add("*.site77.com/**", `re:.*/zzz`, "*/about")
+	if set.Len() != 1003 {
+		t.Fatalf("Len() = %d", set.Len())
+	}
+
+	p, params, ok := set.Find("http://www.site77.com/book/9.html")
+	if !ok || p.String() != "www.site77.com/book/:id.html" || params["id"] != "9" {
+		t.Fatalf("Find = %v, %v, %v", p, params, ok)
+	}
+	if !set.Match("http://q.com/zzz") || set.Match("http://www.site1.com/other") {
+		t.Fatal("Match")
+	}
+}
+
+// 精确主机、子域名和其他规则交替添加时，FindAll 按添加的顺序返回
+func TestURLPatternSetOrder(t *testing.T) {
+	patterns := []string{
+		"*/**",
+		"a.b.example.com/**",
+		"*.example.com/**",
+		`re:.*`,
+		"*.b.example.com/**",
+		"a.b.example.com/x",
+		"*/x",
+		"other.com/**",
+	}
+	set := NewURLPatternSet()
+	if err := set.Add(patterns...); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, p := range set.FindAll("http://a.b.example.com/x") {
+		got = append(got, p.String())
+	}
+	want := append([]string(nil), patterns[:7]...)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("FindAll = %v, want %v", got, want)
+	}
+}
+
+func BenchmarkURLPatternSet(b *testing.B) {
+	set := NewURLPatternSet()
+	for i := 0; i < 5000; i++ {
+		set.Add(fmt.Sprintf("www.site%d.com/book/:id/*.html", i))
+	}
+	for i := 0; i < b.N; i++ {
+		set.Match("http://www.site4999.com/book/1/2.html")
+	}
+}
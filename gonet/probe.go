@@ -0,0 +1,139 @@
+package gonet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// HTTPProbe 描述一次 HTTP 健康检查
+type HTTPProbe struct {
+	Method           string // 默认是 GET
+	URL              string
+	Header           http.Header
+	Body             string
+	Timeout          time.Duration  // 默认是 10 秒
+	ExpectStatus     []int          // 期望的状态码，为空时接受 200 到 399
+	ExpectBody       string         // 响应体中需要包含的内容
+	ExpectBodyRegexp *regexp.Regexp // 响应体需要匹配的正则
+	// 发送请求使用的 Request，为 nil 时使用新的 Request，不跟随跳转，不使用 cookie。
+	// 开启了 Trace 时，结果中才有 Timing
+	Request *Request
+}
+
+// 一次探测的结果
+type ProbeResult struct {
+	Target     string
+	Healthy    bool
+	StatusCode int           // TCP 探测时为 0
+	Latency    time.Duration // 从开始探测到结束的耗时
+	Timing     *Timing       // HTTP 请求的耗时分解，见 Request.EnableTrace
+	Reason     string        // 不健康的原因
+	Err        error         // 连接或者请求的错误
+}
+
+// 发送 probe 描述的请求，按状态码和响应体判断是否健康
+//
+//	result := NetHelper.ProbeHTTP(ctx, &HTTPProbe{URL: "http://127.0.0.1:8080/health", ExpectBody: "ok"})
+func (this *GoNet) ProbeHTTP(ctx context.Context, probe *HTTPProbe) *ProbeResult {
+	result := &ProbeResult{Target: probe.URL}
+
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req := probe.Request
+	if req == nil {
+		req = NewRequest().DisableRedirect().DisableCookie().EnableTrace()
+	}
+	method := probe.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	start := time.Now()
+	builder := req.NewBuilder().Context(ctx)
+	for key, values := range probe.Header {
+		builder.Header(key, strings.Join(values, ", "))
+	}
+	if probe.Body != "" {
+		builder.Body(strings.NewReader(probe.Body), "")
+	}
+	resp, err := builder.Send(method, probe.URL)
+	if err != nil {
+		result.Latency = time.Since(start)
+		result.Err = err
+		result.Reason = err.Error()
+		return result
+	}
+
+	data, err := resp.Bytes()
+	result.Latency = time.Since(start)
+	result.StatusCode = resp.StatusCode()
+	result.Timing = resp.Timing()
+	if err != nil {
+		result.Err = err
+		result.Reason = err.Error()
+		return result
+	}
+
+	result.Reason = probe.check(result.StatusCode, data)
+	result.Healthy = result.Reason == ""
+	return result
+}
+
+// 返回不健康的原因，健康时返回空字符串
+func (this *HTTPProbe) check(statusCode int, body []byte) string {
+	if len(this.ExpectStatus) == 0 {
+		if statusCode < 200 || statusCode > 399 {
+			return fmt.Sprintf("unexpected status %d", statusCode)
+		}
+	} else {
+		ok := false
+		for _, code := range this.ExpectStatus {
+			if code == statusCode {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Sprintf("unexpected status %d, want %v", statusCode, this.ExpectStatus)
+		}
+	}
+
+	if this.ExpectBody != "" && !bytes.Contains(body, []byte(this.ExpectBody)) {
+		return fmt.Sprintf("body does not contain %q", this.ExpectBody)
+	}
+	if this.ExpectBodyRegexp != nil && !this.ExpectBodyRegexp.Match(body) {
+		return fmt.Sprintf("body does not match %s", this.ExpectBodyRegexp.String())
+	}
+
+	return ""
+}
+
+// 连接 address 的 TCP 端口，能连接上就是健康的
+func (this *GoNet) ProbeTCP(ctx context.Context, address string, timeout time.Duration) *ProbeResult {
+	result := &ProbeResult{Target: address}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+		result.Reason = err.Error()
+		return result
+	}
+	conn.Close()
+
+	result.Healthy = true
+	return result
+}
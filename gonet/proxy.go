@@ -0,0 +1,256 @@
+package gonet
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+var ErrUnsupportedProxy = errors.New("gonet: unsupported proxy scheme")
+
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f dialFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+func (f dialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+type proxyURLKey struct{}
+
+// proxyTransport 按代理地址的协议发送请求。
+// http 和 https 代理交给 http.Transport 处理，用户名和密码放在 Proxy-Authorization 中；
+// socks4、socks4a、socks5 和 socks5h 代理为每个代理地址创建一个单独的 http.Transport，
+// 其中 socks4a 和 socks5h 由代理服务器解析域名
+type proxyTransport struct {
+	base   *http.Transport
+	proxy  func(*http.Request) (*url.URL, error)
+	header http.Header
+	socks  map[string]*http.Transport
+	lock   *sync.Mutex
+}
+
+func newProxyTransport(base *http.Transport, proxy func(*http.Request) (*url.URL, error), header http.Header) *proxyTransport {
+	base.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, _ := req.Context().Value(proxyURLKey{}).(*url.URL)
+		return u, nil
+	}
+	if len(header) > 0 {
+		base.ProxyConnectHeader = header
+	}
+
+	return &proxyTransport{
+		base:   base,
+		proxy:  proxy,
+		header: header,
+		socks:  make(map[string]*http.Transport),
+		lock:   &sync.Mutex{},
+	}
+}
+
+func (this *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var u *url.URL
+	if this.proxy != nil {
+		var err error
+		if u, err = this.proxy(req); err != nil {
+			return nil, err
+		}
+	}
+	if u == nil {
+		return this.base.RoundTrip(req)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		req = req.WithContext(context.WithValue(req.Context(), proxyURLKey{}, u))
+		// 不使用 CONNECT 的请求，代理需要的请求头直接加在请求中
+		if req.URL.Scheme == "http" && len(this.header) > 0 {
+			req = req.Clone(req.Context())
+			for key, values := range this.header {
+				req.Header[key] = values
+			}
+		}
+		return this.base.RoundTrip(req)
+	case "socks4", "socks4a", "socks5", "socks5h":
+		t, err := this.socksTransport(u)
+		if err != nil {
+			return nil, err
+		}
+		return t.RoundTrip(req)
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedProxy, u.Scheme)
+}
+
+func (this *proxyTransport) CloseIdleConnections() {
+	this.base.CloseIdleConnections()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, t := range this.socks {
+		t.CloseIdleConnections()
+	}
+}
+
+func (this *proxyTransport) socksTransport(u *url.URL) (*http.Transport, error) {
+	key := u.String()
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	if t, ok := this.socks[key]; ok {
+		return t, nil
+	}
+
+	dial, err := SocksDialer(u, this.base.DialContext)
+	if err != nil {
+		return nil, err
+	}
+
+	t := this.base.Clone()
+	t.Proxy = nil
+	t.DialContext = dial
+	this.socks[key] = t
+
+	return t, nil
+}
+
+// 返回通过 socks 代理 u 建立连接的拨号函数，forward 用来连接代理服务器，为 nil 时使用 net.Dialer
+func SocksDialer(u *url.URL, forward func(ctx context.Context, network, address string) (net.Conn, error)) (func(ctx context.Context, network, address string) (net.Conn, error), error) {
+	if forward == nil {
+		forward = (&net.Dialer{}).DialContext
+	}
+	server := proxyAddress(u)
+
+	switch scheme := strings.ToLower(u.Scheme); scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", server, auth, dialFunc(forward))
+		if err != nil {
+			return nil, err
+		}
+		dialer := d.(proxy.ContextDialer)
+
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			if scheme == "socks5" {
+				var err error
+				if address, err = resolveAddress(ctx, address, false); err != nil {
+					return nil, err
+				}
+			}
+			return dialer.DialContext(ctx, network, address)
+		}, nil
+	case "socks4", "socks4a":
+		userID := ""
+		if u.User != nil {
+			userID = u.User.Username()
+		}
+
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			if scheme == "socks4" {
+				var err error
+				if address, err = resolveAddress(ctx, address, true); err != nil {
+					return nil, err
+				}
+			}
+
+			conn, err := forward(ctx, "tcp", server)
+			if err != nil {
+				return nil, err
+			}
+			if err := socks4Connect(ctx, conn, address, userID); err != nil {
+				conn.Close()
+				return nil, err
+			}
+
+			return conn, nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedProxy, u.Scheme)
+}
+
+// 在本地解析域名，ipv4Only 为 true 时只使用 IPv4 地址
+func resolveAddress(ctx context.Context, address string, ipv4Only bool) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return address, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		if !ipv4Only || addr.IP.To4() != nil {
+			return net.JoinHostPort(addr.IP.String(), port), nil
+		}
+	}
+
+	return "", fmt.Errorf("gonet: no suitable address for %s", host)
+}
+
+// 见 https://www.openssh.com/txt/socks4.protocol 和 socks4a.protocol
+func socks4Connect(ctx context.Context, conn net.Conn, address, userID string) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := []byte{4, 1, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], uint16(port))
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		// socks4a：IP 为 0.0.0.x，域名放在用户名之后
+		req = append(req, 0, 0, 0, 1)
+	} else {
+		req = append(req, ip...)
+	}
+	req = append(append(req, userID...), 0)
+	if ip == nil {
+		req = append(append(req, host...), 0)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 8)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[1] != 90 {
+		return fmt.Errorf("gonet: socks4 connect %s rejected with code %d", address, resp[1])
+	}
+
+	return nil
+}
@@ -0,0 +1,380 @@
+package gonet_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// 代理收到的连接请求
+type socksRequest struct {
+	version  int
+	userID   string // socks4 的用户名，socks5 的用户名和密码用 : 连接
+	ip       net.IP
+	domain   string
+	port     int
+	rejected bool
+}
+
+// 最小的 socks4/4a/5 代理服务器，记录收到的请求。
+// 不管请求的是哪个地址，都连接到 target，这样本地解析出的地址也能访问测试服务器
+type socksServer struct {
+	listener net.Listener
+	target   string
+	reject   bool
+	requests []socksRequest
+	lock     sync.Mutex
+}
+
+func newSocksServer(t *testing.T, target string) *socksServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	this := &socksServer{listener: listener, target: target}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go this.serve(conn)
+		}
+	}()
+
+	return this
+}
+
+func (this *socksServer) Addr() string {
+	return this.listener.Addr().String()
+}
+
+func (this *socksServer) Close() {
+	this.listener.Close()
+}
+
+func (this *socksServer) Requests() []socksRequest {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return append([]socksRequest{}, this.requests...)
+}
+
+func (this *socksServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	version, err := r.ReadByte()
+	if err != nil {
+		return
+	}
+
+	var req socksRequest
+	switch version {
+	case 4:
+		req, err = this.handshake4(r, conn)
+	case 5:
+		req, err = this.handshake5(r, conn)
+	default:
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	this.lock.Lock()
+	this.requests = append(this.requests, req)
+	this.lock.Unlock()
+	if req.rejected {
+		return
+	}
+
+	upstream, err := net.Dial("tcp", this.target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	go io.Copy(upstream, r)
+	io.Copy(conn, upstream)
+}
+
+func readCString(r *bufio.Reader) (string, error) {
+	s, err := r.ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	return s[:len(s)-1], nil
+}
+
+func (this *socksServer) handshake4(r *bufio.Reader, conn net.Conn) (socksRequest, error) {
+	req := socksRequest{version: 4}
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return req, err
+	}
+	req.port = int(binary.BigEndian.Uint16(header[1:3]))
+	req.ip = net.IP(header[3:7])
+
+	var err error
+	if req.userID, err = readCString(r); err != nil {
+		return req, err
+	}
+	// socks4a：0.0.0.x 表示后面跟着域名
+	if ip := req.ip.To4(); ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+		req.ip = nil
+		if req.domain, err = readCString(r); err != nil {
+			return req, err
+		}
+	}
+
+	code := byte(90)
+	if this.reject {
+		code, req.rejected = 91, true
+	}
+	_, err = conn.Write([]byte{0, code, 0, 0, 0, 0, 0, 0})
+	return req, err
+}
+
+func (this *socksServer) handshake5(r *bufio.Reader, conn net.Conn) (socksRequest, error) {
+	req := socksRequest{version: 5}
+	n, err := r.ReadByte()
+	if err != nil {
+		return req, err
+	}
+	methods := make([]byte, n)
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return req, err
+	}
+
+	method := byte(0)
+	for _, m := range methods {
+		if m == 2 {
+			method = 2
+		}
+	}
+	if _, err := conn.Write([]byte{5, method}); err != nil {
+		return req, err
+	}
+	if method == 2 {
+		// 用户名和密码认证 (RFC 1929)
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return req, err
+		}
+		user := make([]byte, buf[1])
+		if _, err := io.ReadFull(r, user); err != nil {
+			return req, err
+		}
+		plen, err := r.ReadByte()
+		if err != nil {
+			return req, err
+		}
+		password := make([]byte, plen)
+		if _, err := io.ReadFull(r, password); err != nil {
+			return req, err
+		}
+		req.userID = string(user) + ":" + string(password)
+		if _, err := conn.Write([]byte{1, 0}); err != nil {
+			return req, err
+		}
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return req, err
+	}
+	switch header[3] {
+	case 1:
+		req.ip = make(net.IP, 4)
+		_, err = io.ReadFull(r, req.ip)
+	case 4:
+		req.ip = make(net.IP, 16)
+		_, err = io.ReadFull(r, req.ip)
+	case 3:
+		var n byte
+		if n, err = r.ReadByte(); err == nil {
+			domain := make([]byte, n)
+			_, err = io.ReadFull(r, domain)
+			req.domain = string(domain)
+		}
+	default:
+		return req, fmt.Errorf("unknown address type %d", header[3])
+	}
+	if err != nil {
+		return req, err
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return req, err
+	}
+	req.port = int(binary.BigEndian.Uint16(port))
+
+	_, err = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	return req, err
+}
+
+func newProxyTarget() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("target " + r.Host))
+	}))
+}
+
+func TestSocksProxy(t *testing.T) {
+	target := newProxyTarget()
+	defer target.Close()
+
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	targetURL := "http://localhost:" + port + "/"
+
+	tests := []struct {
+		scheme string
+		user   string
+		// socks4a 和 socks5h 发送域名，socks4 和 socks5 在本地解析后发送 IP
+		remoteDNS bool
+		userID    string
+	}{
+		{"socks4", "alice", false, "alice"},
+		{"socks4a", "alice", true, "alice"},
+		{"socks5", "", false, ""},
+		{"socks5h", "", true, ""},
+		{"socks5h", "alice:secret", true, "alice:secret"},
+	}
+	for _, tt := range tests {
+		server := newSocksServer(t, target.Listener.Addr().String())
+
+		proxyURL := tt.scheme + "://"
+		if tt.user != "" {
+			proxyURL += tt.user + "@"
+		}
+		proxyURL += server.Addr()
+
+		body, err := gonet.NewRequest().SetProxyURL(proxyURL).GET(targetURL).String()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.scheme, err)
+		}
+		if body != "target localhost:"+port {
+			t.Fatalf("%s: body = %q", tt.scheme, body)
+		}
+
+		requests := server.Requests()
+		if len(requests) != 1 {
+			t.Fatalf("%s: %d proxy requests", tt.scheme, len(requests))
+		}
+		req := requests[0]
+		if want := tt.scheme[len("socks")] - '0'; req.version != int(want) {
+			t.Errorf("%s: version %d", tt.scheme, req.version)
+		}
+		if fmt.Sprint(req.port) != port || req.userID != tt.userID {
+			t.Errorf("%s: port %d, user %q", tt.scheme, req.port, req.userID)
+		}
+		if tt.remoteDNS {
+			if req.domain != "localhost" || req.ip != nil {
+				t.Errorf("%s: domain %q, ip %v, want the domain", tt.scheme, req.domain, req.ip)
+			}
+		} else if req.domain != "" || req.ip == nil || !req.ip.IsLoopback() {
+			t.Errorf("%s: domain %q, ip %v, want a local address", tt.scheme, req.domain, req.ip)
+		}
+		if tt.scheme == "socks4" && req.ip.To4() == nil {
+			t.Errorf("socks4: %v is not an IPv4 address", req.ip)
+		}
+	}
+}
+
+func TestSocks4Rejected(t *testing.T) {
+	server := newSocksServer(t, "127.0.0.1:1")
+	defer server.Close()
+	server.reject = true
+
+	u, _ := url.Parse("socks4a://" + server.Addr())
+	dial, err := gonet.SocksDialer(u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := dial(context.Background(), "tcp", "example.com:80"); err == nil {
+		conn.Close()
+		t.Fatal("rejected connection returned no error")
+	}
+}
+
+// 支持 CONNECT 的 http 代理，记录收到的请求头
+func newConnectProxy(headers chan<- http.Header) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+
+		if r.Method != "CONNECT" {
+			w.Write([]byte("proxied " + r.URL.String()))
+			return
+		}
+
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer upstream.Close()
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+		go io.Copy(upstream, rw)
+		io.Copy(conn, upstream)
+	}))
+}
+
+func TestHTTPProxyHeaders(t *testing.T) {
+	headers := make(chan http.Header, 10)
+	proxy := newConnectProxy(headers)
+	defer proxy.Close()
+
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls " + r.Header.Get("X-Proxy-Token")))
+	}))
+	defer target.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("alice", "secret")
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+
+	req := gonet.NewRequest().
+		SetProxy(proxyURL).
+		SetProxyHeader("X-Proxy-Token", "t1").
+		EnableInsecureTLSSkipVerify()
+
+	// https 通过 CONNECT，代理需要的请求头只发给代理
+	body, err := req.GET(target.URL).String()
+	if err != nil || body != "tls " {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	connect := <-headers
+	if connect.Get("Proxy-Authorization") != auth || connect.Get("X-Proxy-Token") != "t1" {
+		t.Fatalf("CONNECT headers = %v", connect)
+	}
+
+	// http 不使用 CONNECT，请求头直接加在请求中
+	body, err = req.GET("http://example.invalid/page").String()
+	if err != nil || body != "proxied http://example.invalid/page" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	plain := <-headers
+	if plain.Get("Proxy-Authorization") != auth || plain.Get("X-Proxy-Token") != "t1" {
+		t.Fatalf("proxy headers = %v", plain)
+	}
+}
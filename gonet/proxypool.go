@@ -0,0 +1,418 @@
+package gonet
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNoProxy = errors.New("gonet: no alive proxy")
+
+type ProxyStrategy int
+
+const (
+	// 依次使用每个代理
+	ProxyRoundRobin ProxyStrategy = iota
+	// 随机选择代理
+	ProxyRandom
+	// 同一个主机一直使用同一个代理，代理失效后再换一个
+	ProxySticky
+)
+
+// ProxyStats 是一个代理的统计数据
+type ProxyStats struct {
+	URL                 string
+	Alive               bool
+	Requests            int64
+	Successes           int64
+	Failures            int64
+	ConsecutiveFailures int
+	AvgLatency          time.Duration
+	LastError           string
+	LastUsed            time.Time
+	LastChecked         time.Time
+}
+
+// ProxyPool 管理多个代理，按策略为每个请求选择代理。
+// 连续失败 maxFails 次的代理被标记为失效，不再使用，
+// 后台每隔 checkInterval 用 GoNet.Ping 检查失效的代理，能连接上就恢复使用。
+//
+//	pool, err := gonet.NewProxyPool("http://1.2.3.4:8080", "socks5://5.6.7.8:1080")
+//	defer pool.Close()
+//	req := gonet.NewRequest().SetProxyPool(pool)
+type ProxyPool struct {
+	proxies       []*proxyState
+	strategy      ProxyStrategy
+	maxFails      int
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	next          int
+	sticky        map[string]*stickyProxy
+	lastSweep     time.Time
+	rand          *rand.Rand
+	checking      bool
+	closed        chan struct{}
+	closeOnce     sync.Once
+	lock          *sync.Mutex
+}
+
+// 超过这个时间没有请求的主机，不再固定使用之前的代理
+const stickyIdleTimeout = 10 * time.Minute
+
+type stickyProxy struct {
+	proxy *proxyState
+	used  time.Time
+}
+
+type proxyState struct {
+	url   *url.URL
+	stats ProxyStats
+	total time.Duration // 成功请求的总耗时，用来计算平均耗时
+}
+
+func NewProxyPool(proxyURLs ...string) (*ProxyPool, error) {
+	this := &ProxyPool{
+		strategy:      ProxyRoundRobin,
+		maxFails:      3,
+		checkInterval: 30 * time.Second,
+		checkTimeout:  5 * time.Second,
+		sticky:        make(map[string]*stickyProxy),
+		lastSweep:     time.Now(),
+		rand:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		closed:        make(chan struct{}),
+		lock:          &sync.Mutex{},
+	}
+
+	for _, proxyURL := range proxyURLs {
+		if err := this.Add(proxyURL); err != nil {
+			return nil, err
+		}
+	}
+
+	return this, nil
+}
+
+func (this *ProxyPool) Add(proxyURL string) error {
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return errors.New("gonet: invalid proxy url " + proxyURL)
+	}
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, p := range this.proxies {
+		if p.url.String() == u.String() {
+			return nil
+		}
+	}
+	this.proxies = append(this.proxies, &proxyState{url: u, stats: ProxyStats{URL: u.String(), Alive: true}})
+
+	return nil
+}
+
+func (this *ProxyPool) Remove(proxyURL string) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for i, p := range this.proxies {
+		if p.url.String() == proxyURL {
+			this.proxies = append(this.proxies[:i:i], this.proxies[i+1:]...)
+			break
+		}
+	}
+	for host, s := range this.sticky {
+		if s.proxy.url.String() == proxyURL {
+			delete(this.sticky, host)
+		}
+	}
+}
+
+func (this *ProxyPool) SetStrategy(strategy ProxyStrategy) *ProxyPool {
+	this.lock.Lock()
+	this.strategy = strategy
+	this.lock.Unlock()
+	return this
+}
+
+// 连续失败多少次后标记为失效
+func (this *ProxyPool) SetMaxFails(maxFails int) *ProxyPool {
+	this.lock.Lock()
+	this.maxFails = maxFails
+	this.lock.Unlock()
+	return this
+}
+
+// 检查失效代理的间隔和连接超时时间
+func (this *ProxyPool) SetHealthCheck(interval, timeout time.Duration) *ProxyPool {
+	this.lock.Lock()
+	this.checkInterval = interval
+	this.checkTimeout = timeout
+	this.lock.Unlock()
+	return this
+}
+
+// 可以直接传给 Request.SetProxyFunc。没有可用的代理时返回 ErrNoProxy
+func (this *ProxyPool) Proxy(req *http.Request) (*url.URL, error) {
+	p, err := this.pick(req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	if used, ok := req.Context().Value(proxyUsedKey{}).(*proxyUsed); ok {
+		used.set(p)
+	}
+
+	return p.url, nil
+}
+
+// 记录使用 proxyURL 的请求结果，err 为 nil 表示成功
+func (this *ProxyPool) Report(proxyURL *url.URL, latency time.Duration, err error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	for _, p := range this.proxies {
+		if p.url.String() == proxyURL.String() {
+			this.report(p, latency, err)
+			return
+		}
+	}
+}
+
+// 记录每个请求使用的代理和结果。
+// 请求出错、代理返回 407 或者 502 时算作失败，请求被取消时不计入
+func (this *ProxyPool) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			used := &proxyUsed{}
+			req = req.WithContext(context.WithValue(req.Context(), proxyUsedKey{}, used))
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			p := used.get()
+			if p == nil || req.Context().Err() != nil {
+				return resp, err
+			}
+
+			outcome := err
+			if err == nil && (resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode == http.StatusBadGateway) {
+				outcome = errors.New(resp.Status)
+			}
+
+			this.lock.Lock()
+			this.report(p, time.Since(start), outcome)
+			this.lock.Unlock()
+
+			return resp, err
+		})
+	}
+}
+
+func (this *ProxyPool) Stats() []ProxyStats {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	stats := make([]ProxyStats, 0, len(this.proxies))
+	for _, p := range this.proxies {
+		stats = append(stats, p.stats)
+	}
+
+	return stats
+}
+
+// 可用的代理数量
+func (this *ProxyPool) Alive() int {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	n := 0
+	for _, p := range this.proxies {
+		if p.stats.Alive {
+			n++
+		}
+	}
+
+	return n
+}
+
+// 停止后台检查
+func (this *ProxyPool) Close() error {
+	this.closeOnce.Do(func() {
+		close(this.closed)
+	})
+
+	return nil
+}
+
+func (this *ProxyPool) pick(host string) (*proxyState, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	alive := make([]*proxyState, 0, len(this.proxies))
+	for _, p := range this.proxies {
+		if p.stats.Alive {
+			alive = append(alive, p)
+		}
+	}
+	if len(alive) == 0 {
+		return nil, ErrNoProxy
+	}
+
+	now := time.Now()
+	var p *proxyState
+	switch this.strategy {
+	case ProxyRandom:
+		p = alive[this.rand.Intn(len(alive))]
+	case ProxySticky:
+		if now.Sub(this.lastSweep) > stickyIdleTimeout {
+			this.lastSweep = now
+			for h, s := range this.sticky {
+				if now.Sub(s.used) > stickyIdleTimeout {
+					delete(this.sticky, h)
+				}
+			}
+		}
+
+		if s, ok := this.sticky[host]; ok && s.proxy.stats.Alive {
+			p = s.proxy
+			s.used = now
+		} else {
+			p = alive[this.next%len(alive)]
+			this.next++
+			this.sticky[host] = &stickyProxy{proxy: p, used: now}
+		}
+	default:
+		p = alive[this.next%len(alive)]
+		this.next++
+	}
+
+	p.stats.LastUsed = now
+	return p, nil
+}
+
+// 调用时已经持有锁
+func (this *ProxyPool) report(p *proxyState, latency time.Duration, err error) {
+	p.stats.Requests++
+	if err == nil {
+		p.stats.Successes++
+		p.stats.ConsecutiveFailures = 0
+		p.total += latency
+		p.stats.AvgLatency = p.total / time.Duration(p.stats.Successes)
+		return
+	}
+
+	p.stats.Failures++
+	p.stats.ConsecutiveFailures++
+	p.stats.LastError = err.Error()
+	if this.maxFails > 0 && p.stats.ConsecutiveFailures >= this.maxFails && p.stats.Alive {
+		p.stats.Alive = false
+		if !this.checking {
+			this.checking = true
+			go this.healthCheck()
+		}
+	}
+}
+
+// 有失效的代理时才运行，全部恢复后退出
+func (this *ProxyPool) healthCheck() {
+	for {
+		this.lock.Lock()
+		interval, timeout := this.checkInterval, this.checkTimeout
+		this.lock.Unlock()
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-this.closed:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		this.lock.Lock()
+		var dead []*proxyState
+		for _, p := range this.proxies {
+			if !p.stats.Alive {
+				dead = append(dead, p)
+			}
+		}
+		this.lock.Unlock()
+
+		for _, p := range dead {
+			err := NetHelper.Ping("tcp", proxyAddress(p.url), timeout)
+
+			this.lock.Lock()
+			p.stats.LastChecked = time.Now()
+			if err == nil {
+				p.stats.Alive = true
+				p.stats.ConsecutiveFailures = 0
+			} else {
+				p.stats.LastError = err.Error()
+			}
+			this.lock.Unlock()
+		}
+
+		this.lock.Lock()
+		done := true
+		for _, p := range this.proxies {
+			if !p.stats.Alive {
+				done = false
+				break
+			}
+		}
+		if done {
+			this.checking = false
+		}
+		this.lock.Unlock()
+
+		if done {
+			return
+		}
+	}
+}
+
+// 代理的 host:port，没有端口时使用协议的默认端口
+func proxyAddress(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	port := "80"
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		port = "443"
+	case "socks4", "socks4a", "socks5", "socks5h":
+		port = "1080"
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+type proxyUsedKey struct{}
+
+// 记录 Transport 为请求选择的代理
+type proxyUsed struct {
+	proxy *proxyState
+	lock  sync.Mutex
+}
+
+func (this *proxyUsed) set(p *proxyState) {
+	this.lock.Lock()
+	this.proxy = p
+	this.lock.Unlock()
+}
+
+func (this *proxyUsed) get() *proxyState {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.proxy
+}
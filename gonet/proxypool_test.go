@@ -0,0 +1,90 @@
+package gonet
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSetProxyPoolNil(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer srv.Close()
+
+	pool, _ := NewProxyPool("http://127.0.0.1:1")
+	defer pool.Close()
+
+	req := NewRequest().SetProxyPool(pool).SetProxyPool(nil)
+	body, err := req.GET(srv.URL).String()
+	if err != nil || body != "direct" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+}
+
+func TestProxyPoolStrategy(t *testing.T) {
+	pool, _ := NewProxyPool("http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://10.0.0.3:8080")
+	defer pool.Close()
+
+	pick := func(host string) string {
+		req, _ := http.NewRequest("GET", "http://"+host+"/", nil)
+		u, err := pool.Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Host
+	}
+
+	if a, b := pick("a.com"), pick("a.com"); a == b {
+		t.Fatalf("round robin picked %s twice", a)
+	}
+
+	pool.SetStrategy(ProxySticky)
+	first := pick("b.com")
+	for i := 0; i < 5; i++ {
+		if got := pick("b.com"); got != first {
+			t.Fatalf("sticky picked %s, then %s", first, got)
+		}
+	}
+
+	// 代理失效后换一个
+	u, _ := url.Parse("http://" + first)
+	for i := 0; i < 3; i++ {
+		pool.Report(u, time.Millisecond, errors.New("failed"))
+	}
+	if got := pick("b.com"); got == first {
+		t.Fatalf("sticky kept dead proxy %s", got)
+	}
+	if pool.Alive() != 2 {
+		t.Fatalf("Alive() = %d", pool.Alive())
+	}
+}
+
+func TestProxyPoolStickyEviction(t *testing.T) {
+	pool, _ := NewProxyPool("http://10.0.0.1:8080")
+	defer pool.Close()
+	pool.SetStrategy(ProxySticky)
+
+	pool.pick("old.com")
+	pool.pick("new.com")
+
+	pool.lock.Lock()
+	old := time.Now().Add(-2 * stickyIdleTimeout)
+	pool.sticky["old.com"].used = old
+	pool.lastSweep = old
+	pool.lock.Unlock()
+
+	pool.pick("new.com")
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+	if _, ok := pool.sticky["old.com"]; ok {
+		t.Error("idle host was not evicted")
+	}
+	if _, ok := pool.sticky["new.com"]; !ok {
+		t.Error("recent host was evicted")
+	}
+}
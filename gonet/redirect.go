@@ -0,0 +1,137 @@
+package gonet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var ErrTooManyRedirects = errors.New("gonet: too many redirects")
+
+// Redirect 是跳转链中的一步：请求 URL 返回了 StatusCode，跳转到 Location
+type Redirect struct {
+	URL        *url.URL
+	StatusCode int
+	Location   *url.URL
+}
+
+// 跳转时需要去掉的认证信息
+var authHeaders = []string{"Authorization", "Cookie", "Cookie2"}
+
+// 是否自动跳转，默认跳转。不跳转时返回 3xx 响应，跳转地址可以用 Response.Location 读取
+func (this *Request) EnableRedirect() *Request {
+	return this.FollowRedirect(true)
+}
+func (this *Request) DisableRedirect() *Request {
+	return this.FollowRedirect(false)
+}
+func (this *Request) FollowRedirect(follow bool) *Request {
+	this.noRedirect = !follow
+	return this
+}
+
+// 最多跳转次数，默认是 10。超过后返回 ErrTooManyRedirects
+func (this *Request) SetMaxRedirects(max int) *Request {
+	this.maxRedirects = max
+	return this
+}
+
+// 只跳转到相同的主机（包括端口），跳转到其他主机时不再跳转，返回 3xx 响应
+func (this *Request) SetSameHostRedirect(sameHost bool) *Request {
+	this.sameHostRedirect = sameHost
+	return this
+}
+
+// 跳转到其他主机时是否保留 Authorization 和 Cookie 请求头，默认去掉。
+// 只保留请求时设置的请求头，CookieJar 中的 cookie 不受影响
+func (this *Request) SetKeepAuthOnRedirect(keep bool) *Request {
+	this.keepAuthOnRedirect = keep
+	return this
+}
+
+// 用作 http.Client.CheckRedirect，next 是 client 原来的 CheckRedirect
+func (this *Request) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if this.noRedirect {
+			return http.ErrUseLastResponse
+		}
+
+		first := via[0]
+		sameHost := strings.EqualFold(req.URL.Host, first.URL.Host)
+		if this.sameHostRedirect && !sameHost {
+			return http.ErrUseLastResponse
+		}
+
+		if len(via) > this.maxRedirects {
+			return fmt.Errorf("%w: stopped after %d redirects", ErrTooManyRedirects, this.maxRedirects)
+		}
+
+		for _, key := range authHeaders {
+			switch {
+			case this.keepAuthOnRedirect:
+				if values, ok := first.Header[key]; ok && req.Header.Get(key) == "" {
+					req.Header[key] = values
+				}
+			case !sameHost:
+				// http.Client 跳转到子域名时会保留这些请求头
+				req.Header.Del(key)
+			}
+		}
+
+		if next != nil {
+			if err := next(req, via); err != nil {
+				return err
+			}
+		}
+
+		recordRedirect(req)
+		return nil
+	}
+}
+
+type redirectKey struct{}
+
+// 记录一次请求的跳转链，重试时重新开始
+type redirectRecorder struct {
+	redirects []*Redirect
+	lock      sync.Mutex
+}
+
+func withRedirectRecorder(ctx context.Context) (context.Context, *redirectRecorder) {
+	recorder := &redirectRecorder{}
+	return context.WithValue(ctx, redirectKey{}, recorder), recorder
+}
+
+func recordRedirect(req *http.Request) {
+	recorder, ok := req.Context().Value(redirectKey{}).(*redirectRecorder)
+	if !ok || req.Response == nil {
+		return
+	}
+
+	recorder.lock.Lock()
+	recorder.redirects = append(recorder.redirects, &Redirect{
+		URL:        req.Response.Request.URL,
+		StatusCode: req.Response.StatusCode,
+		Location:   req.URL,
+	})
+	recorder.lock.Unlock()
+}
+
+func resetRedirects(ctx context.Context) {
+	if recorder, ok := ctx.Value(redirectKey{}).(*redirectRecorder); ok {
+		recorder.lock.Lock()
+		recorder.redirects = nil
+		recorder.lock.Unlock()
+	}
+}
+
+func (this *redirectRecorder) Redirects() []*Redirect {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return append([]*Redirect(nil), this.redirects...)
+}
@@ -0,0 +1,186 @@
+package gonet_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// a 和 b 的端口不同，是两个主机
+func newRedirectServers() (a, b *httptest.Server) {
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization") + "|" + r.Header.Get("Cookie")))
+	}
+
+	b = httptest.NewServer(http.HandlerFunc(echo))
+
+	var flaky int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", echo)
+	mux.HandleFunc("/same", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, b.URL+"/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/other", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	// 第一次跳转到一个返回 503 的地址，重试时直接返回
+	mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&flaky, 1) == 1 {
+			http.Redirect(w, r, "/unavailable", http.StatusFound)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/unavailable", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	a = httptest.NewServer(mux)
+
+	return a, b
+}
+
+func newAuthRequest() *gonet.Request {
+	return gonet.NewRequest().
+		AddHeader("Authorization", "Bearer token").
+		AddHeader("Cookie", "session=1")
+}
+
+func TestRedirectAuthHeaders(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	tests := []struct {
+		name string
+		req  *gonet.Request
+		path string
+		want string
+	}{
+		{"same host keeps auth", newAuthRequest(), "/same", "Bearer token|session=1"},
+		{"other host strips auth", newAuthRequest(), "/other", "|"},
+		{"keep auth on redirect", newAuthRequest().SetKeepAuthOnRedirect(true), "/other", "Bearer token|session=1"},
+	}
+	for _, tt := range tests {
+		body, err := tt.req.GET(a.URL + tt.path).String()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if body != tt.want {
+			t.Errorf("%s: headers = %q, want %q", tt.name, body, tt.want)
+		}
+	}
+}
+
+func TestRedirectChain(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	resp, err := gonet.NewRequest().Do("GET", a.URL+"/chain", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+
+	redirects := resp.Redirects()
+	if len(redirects) != 2 {
+		t.Fatalf("%d redirects, want 2", len(redirects))
+	}
+	want := []struct {
+		url, location string
+		status        int
+	}{
+		{a.URL + "/chain", a.URL + "/other", http.StatusMovedPermanently},
+		{a.URL + "/other", b.URL + "/echo", http.StatusFound},
+	}
+	for i, w := range want {
+		r := redirects[i]
+		if r.URL.String() != w.url || r.Location.String() != w.location || r.StatusCode != w.status {
+			t.Errorf("redirect %d = %s %d -> %s", i, r.URL, r.StatusCode, r.Location)
+		}
+	}
+	if resp.URL().String() != b.URL+"/echo" {
+		t.Errorf("URL() = %s", resp.URL())
+	}
+}
+
+func TestRedirectSameHostOnly(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	req := gonet.NewRequest().SetSameHostRedirect(true)
+
+	// 同一个主机内正常跳转
+	if body, err := req.GET(a.URL + "/same").String(); err != nil || body != "|" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+
+	resp, err := req.Do("GET", a.URL+"/other", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if resp.StatusCode() != http.StatusFound || resp.Location().String() != b.URL+"/echo" {
+		t.Fatalf("status = %d, Location() = %v", resp.StatusCode(), resp.Location())
+	}
+	if len(resp.Redirects()) != 0 {
+		t.Fatalf("Redirects() = %v", resp.Redirects())
+	}
+}
+
+func TestRedirectDisabled(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	resp, err := gonet.NewRequest().DisableRedirect().Do("GET", a.URL+"/same", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Close()
+	if resp.StatusCode() != http.StatusFound || resp.Location().String() != a.URL+"/echo" {
+		t.Fatalf("status = %d, Location() = %v", resp.StatusCode(), resp.Location())
+	}
+}
+
+func TestRedirectTooMany(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	_, err := gonet.NewRequest().SetMaxRedirects(3).Do("GET", a.URL+"/loop", nil, nil, nil)
+	if !errors.Is(err, gonet.ErrTooManyRedirects) {
+		t.Fatalf("err = %v, want ErrTooManyRedirects", err)
+	}
+}
+
+func TestRedirectsResetOnRetry(t *testing.T) {
+	a, b := newRedirectServers()
+	defer a.Close()
+	defer b.Close()
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond))
+	resp, err := req.Do("GET", a.URL+"/flaky", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.String(); body != "ok" || resp.Retries() != 1 {
+		t.Fatalf("body = %q, Retries() = %d", body, resp.Retries())
+	}
+	// 第一次请求的跳转不算在内
+	if len(resp.Redirects()) != 0 {
+		t.Fatalf("Redirects() = %v, want none", resp.Redirects())
+	}
+}
@@ -0,0 +1,334 @@
+package gonet
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrNoResponse         = errors.New("gonet: no response")
+	ErrBodyConsumed       = errors.New("gonet: response body has been consumed")
+	ErrIncompleteDownload = errors.New("gonet: incomplete download")
+)
+
+// Response 是一次请求的结果，创建后不会再被修改，可以在多个 goroutine 中读取。
+// 响应体在第一次读取时才会从连接中读出，之后的读取都返回同一份数据。
+type Response struct {
+	request    *Request
+	response   *http.Response
+	statusCode int
+	header     http.Header
+	url        *url.URL
+	cost       time.Duration
+	retries    int
+	dump       []byte
+	timing     *timingRecorder
+	redirects  []*Redirect
+
+	characterEncoding string
+	maxBodySize       int64
+	charsetOnce       sync.Once
+	charset           string
+
+	once     sync.Once
+	data     []byte
+	err      error
+	consumed bool
+}
+
+// 状态码
+func (this *Response) StatusCode() int {
+	return this.statusCode
+}
+
+// 响应头
+func (this *Response) Header() http.Header {
+	return this.header
+}
+
+// 最终的 URL，如果发生了跳转，则是跳转后的地址
+func (this *Response) URL() *url.URL {
+	return this.url
+}
+
+// 跳转链，按顺序记录每一次跳转，没有跳转时为空
+func (this *Response) Redirects() []*Redirect {
+	return this.redirects
+}
+
+// Location 响应头转换成的绝对地址，例如不跳转时 3xx 响应的跳转地址。没有 Location 时返回 nil
+func (this *Response) Location() *url.URL {
+	location, err := this.response.Location()
+	if err != nil {
+		return nil
+	}
+
+	return location
+}
+
+// 请求耗时，包含重试的时间
+func (this *Response) Cost() time.Duration {
+	return this.cost
+}
+
+// 重试次数，0 表示第一次就成功了
+func (this *Response) Retries() int {
+	return this.retries
+}
+
+// 是否从缓存返回
+func (this *Response) FromCache() bool {
+	return this.header.Get(CacheHeader) != ""
+}
+
+// 开启 debug 后，请求和响应头的原始数据
+func (this *Response) Dump() []byte {
+	return this.dump
+}
+
+// 开启 trace 后，最后一次请求的耗时。没有开启或者命中缓存时返回 nil，
+// 响应体读完或者关闭之前 Transfer 和 Total 为 0
+func (this *Response) Timing() *Timing {
+	if this.timing == nil {
+		return nil
+	}
+	tracer := this.timing.get()
+	if tracer == nil {
+		return nil
+	}
+
+	timing := tracer.timing()
+	return &timing
+}
+
+// 原始的 *http.Response，它的 Body 由 Response 负责读取和关闭
+func (this *Response) Raw() *http.Response {
+	return this.response
+}
+
+// 不需要读取响应体时，调用 Close 释放连接
+func (this *Response) Close() error {
+	var err error
+	this.once.Do(func() {
+		this.consumed = true
+		err = this.response.Body.Close()
+	})
+
+	return err
+}
+
+func (this *Response) Bytes() ([]byte, error) {
+	this.once.Do(func() {
+		defer this.response.Body.Close()
+		this.data, this.err = ioutil.ReadAll(newMaxBytesReader(this.response.Body, this.maxBodySize))
+		if this.err != nil {
+			this.err = this.contextErr(this.err)
+		}
+	})
+
+	if this.consumed {
+		return nil, ErrBodyConsumed
+	}
+
+	return this.data, this.err
+}
+
+// 转换成 utf-8 的响应体，编码由 Charset 决定
+func (this *Response) String() (string, error) {
+	data, err := this.Bytes()
+	if err != nil {
+		return "", err
+	}
+
+	return string(decodeCharset(data, this.Charset())), nil
+}
+
+// 响应体使用的编码。设置了 Request.SetCharacterEncoding 时使用设置的编码，
+// 否则依次根据 Content-Type、BOM、<meta charset> 和内容统计检测。
+// 不是文本的响应返回空字符串
+func (this *Response) Charset() string {
+	this.charsetOnce.Do(func() {
+		if this.characterEncoding != "" {
+			if _, name := charset.Lookup(this.characterEncoding); name != "" {
+				this.charset = name
+				return
+			}
+		}
+
+		data, _ := this.Bytes()
+		this.charset = DetectCharset(data, this.header.Get("Content-Type"))
+	})
+
+	return this.charset
+}
+
+func (this *Response) JSON(v interface{}) error {
+	data, err := this.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (this *Response) XML(v interface{}) error {
+	data, err := this.Bytes()
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(data, v)
+}
+
+// 直接从连接读取响应体，不缓存在内存中，之后再调用 Bytes 等方法会返回 ErrBodyConsumed
+func (this *Response) stream(f func(io.Reader) error) error {
+	err := ErrBodyConsumed
+	this.once.Do(func() {
+		this.consumed = true
+		defer this.response.Body.Close()
+		if err = f(this.response.Body); err != nil {
+			err = this.contextErr(err)
+		}
+	})
+
+	return err
+}
+
+// 保存到文件。先写入 fileName.part，长度与 Content-Length 一致后再重命名为 fileName。
+// 如果响应体还没有被读取，将直接从连接写入文件，不会缓存在内存中，
+// 之后再调用 Bytes 等方法会返回 ErrBodyConsumed
+func (this *Response) Save(fileName string) error {
+	part := fileName + partSuffix
+	if err := this.writeFile(part, 0); err != nil {
+		os.Remove(part)
+		return err
+	}
+
+	return os.Rename(part, fileName)
+}
+
+// 把响应体写入文件。offset 大于 0 时追加到文件末尾，offset 是文件中已有的字节数
+func (this *Response) writeFile(fileName string, offset int64) error {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	f, err := os.OpenFile(fileName, flag, 0666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	streamed := false
+	this.once.Do(func() {
+		streamed = true
+		this.consumed = true
+		this.err = this.download(f, offset)
+	})
+
+	if streamed {
+		if this.err != nil {
+			return this.err
+		}
+	} else {
+		data, err := this.Bytes()
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if this.response.ContentLength < 0 {
+		return nil
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if want := offset + this.response.ContentLength; info.Size() != want {
+		return fmt.Errorf("%w: got %d bytes, want %d", ErrIncompleteDownload, info.Size(), want)
+	}
+
+	return nil
+}
+
+// offset 是已经下载的字节数，用于断点续传时计算进度
+func (this *Response) download(w io.Writer, offset int64) error {
+	bodyReader := this.response.Body
+	defer bodyReader.Close()
+
+	if len(this.request.downloadCallbacks) == 0 {
+		_, err := io.Copy(w, bodyReader)
+		if err != nil {
+			return this.contextErr(err)
+		}
+		return nil
+	}
+
+	readBytes := make([]byte, 1024)
+	total := this.response.ContentLength
+	if total >= 0 {
+		total += offset
+	}
+	current := offset
+	var lastTime time.Time
+
+	defer func() {
+		this.request.handleOnDownload(current, total)
+	}()
+
+	for {
+		n, err := bodyReader.Read(readBytes)
+		if n > 0 {
+			if _, err := w.Write(readBytes[:n]); err != nil {
+				return err
+			}
+			current += int64(n)
+			nowTime := time.Now()
+			if nowTime.Sub(lastTime) > this.request.downloadProgressInterval {
+				lastTime = nowTime
+				this.request.handleOnDownload(current, total)
+			}
+			if total > 0 && current >= total {
+				break
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return this.contextErr(err)
+		}
+	}
+
+	return nil
+}
+
+// 请求的 ctx 被取消时，读取响应体会返回连接关闭之类的错误，这里换成 ctx 的错误
+func (this *Response) contextErr(err error) error {
+	if this.response.Request == nil {
+		return err
+	}
+
+	if ctxErr := this.response.Request.Context().Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
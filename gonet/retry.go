@@ -0,0 +1,239 @@
+package gonet
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy 决定一次请求之后是否需要重试，以及重试前需要等待多久。
+// attempt 是已经完成的请求次数减一，第一次请求之后为 0；
+// elapsed 是从第一次请求开始经过的时间；resp 和 err 只有一个不为 nil
+type RetryPolicy interface {
+	NextRetry(attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// Backoff 是指数退避的重试策略，等待时间每次乘以 multiplier，并加上随机抖动
+type Backoff struct {
+	maxRetries      int // 最多重试次数。如果是 -1，则一直重试
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	multiplier      float64
+	jitter          float64 // 随机抖动的比例，0 到 1 之间
+	maxElapsedTime  time.Duration
+	retryStatus     map[int]bool
+	retryError      func(error) bool
+}
+
+// maxInterval 为 0 时，退避的等待时间的上限
+const maxBackoffInterval = time.Hour
+
+// 默认重试 3 次，等待时间从 500ms 开始，最长 30s，
+// 对 408、429、500、502、503、504 以及网络错误进行重试
+func NewBackoff() *Backoff {
+	this := &Backoff{
+		maxRetries:      3,
+		initialInterval: 500 * time.Millisecond,
+		maxInterval:     30 * time.Second,
+		multiplier:      2,
+		jitter:          0.5,
+		retryError:      IsRetryableError,
+	}
+	this.SetRetryStatus(
+		http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	)
+
+	return this
+}
+
+func (this *Backoff) SetMaxRetries(maxRetries int) *Backoff {
+	this.maxRetries = maxRetries
+	return this
+}
+
+func (this *Backoff) SetInitialInterval(interval time.Duration) *Backoff {
+	this.initialInterval = interval
+	return this
+}
+
+// 两次请求之间最长的等待时间。响应头中的 Retry-After 超过这个时间时不再重试。
+// 0 表示不限制 Retry-After，退避的等待时间最长为 1 小时
+func (this *Backoff) SetMaxInterval(interval time.Duration) *Backoff {
+	this.maxInterval = interval
+	return this
+}
+
+func (this *Backoff) SetMultiplier(multiplier float64) *Backoff {
+	this.multiplier = multiplier
+	return this
+}
+
+func (this *Backoff) SetJitter(jitter float64) *Backoff {
+	this.jitter = math.Max(0, math.Min(1, jitter))
+	return this
+}
+
+// 从第一次请求开始，超过这个时间就不再重试。0 表示不限制
+func (this *Backoff) SetMaxElapsedTime(maxElapsedTime time.Duration) *Backoff {
+	this.maxElapsedTime = maxElapsedTime
+	return this
+}
+
+// 需要重试的状态码，会替换掉默认的状态码
+func (this *Backoff) SetRetryStatus(codes ...int) *Backoff {
+	this.retryStatus = make(map[int]bool, len(codes))
+	for _, code := range codes {
+		this.retryStatus[code] = true
+	}
+	return this
+}
+
+// 判断哪些错误需要重试，默认是 IsRetryableError
+func (this *Backoff) SetRetryError(retryError func(error) bool) *Backoff {
+	this.retryError = retryError
+	return this
+}
+
+func (this *Backoff) NextRetry(attempt int, elapsed time.Duration, resp *http.Response, err error) (time.Duration, bool) {
+	if this.maxRetries != -1 && attempt >= this.maxRetries {
+		return 0, false
+	}
+
+	if err != nil {
+		if this.retryError == nil || !this.retryError(err) {
+			return 0, false
+		}
+	} else if !this.retryStatus[resp.StatusCode] {
+		return 0, false
+	}
+
+	wait := this.interval(attempt)
+	if after, ok := RetryAfter(resp); ok {
+		// 服务器要求等待的时间超过 maxInterval 时不再重试，避免调用者被长时间阻塞
+		if this.maxInterval > 0 && after > this.maxInterval {
+			return 0, false
+		}
+		wait = after
+	}
+
+	if this.maxElapsedTime > 0 && elapsed+wait > this.maxElapsedTime {
+		return 0, false
+	}
+
+	return wait, true
+}
+
+func (this *Backoff) interval(attempt int) time.Duration {
+	ceiling := float64(this.maxInterval)
+	if this.maxInterval <= 0 {
+		ceiling = float64(maxBackoffInterval)
+	}
+
+	// 重试次数很多时 multiplier^attempt 会溢出，先限制在 ceiling 以内再转换成 time.Duration
+	interval := float64(this.initialInterval) * math.Pow(this.multiplier, float64(attempt))
+	if math.IsNaN(interval) || interval < 0 {
+		interval = 0
+	}
+	if interval > ceiling {
+		interval = ceiling
+	}
+
+	if this.jitter > 0 {
+		delta := this.jitter * interval
+		interval = interval - delta + rand.Float64()*2*delta
+	}
+	if interval >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(interval)
+}
+
+// 网络错误、超时以及连接被意外关闭时返回 true；
+// 请求被取消、证书错误等重试也不会成功的错误返回 false
+func IsRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var certErr x509.CertificateInvalidError
+	var hostErr x509.HostnameError
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &certErr) || errors.As(err, &hostErr) || errors.As(err, &authorityErr) {
+		return false
+	}
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	// *url.Error 本身也实现了 net.Error，需要判断它包装的错误
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// 等待 d，ctx 被取消时提前返回 ctx 的错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// 解析响应头中的 Retry-After，支持秒数和 HTTP 日期两种格式
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := time.Until(t)
+	if wait < 0 {
+		wait = 0
+	}
+
+	return wait, true
+}
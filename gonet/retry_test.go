@@ -0,0 +1,181 @@
+package gonet_test
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+	"github.com/zhuomouren/gohelpers/gonet/gonettest"
+)
+
+func statusResponse(code int, retryAfter string) *http.Response {
+	resp := &http.Response{StatusCode: code, Header: http.Header{}}
+	if retryAfter != "" {
+		resp.Header.Set("Retry-After", retryAfter)
+	}
+	return resp
+}
+
+func TestBackoffNextRetry(t *testing.T) {
+	backoff := gonet.NewBackoff().SetJitter(0)
+
+	tests := []struct {
+		attempt int
+		resp    *http.Response
+		wait    time.Duration
+		ok      bool
+	}{
+		{0, statusResponse(503, ""), 500 * time.Millisecond, true},
+		{1, statusResponse(503, ""), time.Second, true},
+		{2, statusResponse(429, ""), 2 * time.Second, true},
+		{3, statusResponse(503, ""), 0, false},
+		{0, statusResponse(404, ""), 0, false},
+		{0, statusResponse(200, ""), 0, false},
+	}
+	for _, tt := range tests {
+		wait, ok := backoff.NextRetry(tt.attempt, 0, tt.resp, nil)
+		if wait != tt.wait || ok != tt.ok {
+			t.Errorf("NextRetry(%d, %d) = %v, %v, want %v, %v", tt.attempt, tt.resp.StatusCode, wait, ok, tt.wait, tt.ok)
+		}
+	}
+}
+
+func TestBackoffMaxInterval(t *testing.T) {
+	backoff := gonet.NewBackoff().SetJitter(0).SetMaxRetries(-1).SetMaxInterval(5 * time.Second)
+
+	wait, ok := backoff.NextRetry(20, 0, statusResponse(503, ""), nil)
+	if !ok || wait != 5*time.Second {
+		t.Fatalf("NextRetry = %v, %v, want 5s, true", wait, ok)
+	}
+
+	backoff.SetMaxElapsedTime(time.Minute)
+	if _, ok := backoff.NextRetry(20, 58*time.Second, statusResponse(503, ""), nil); ok {
+		t.Fatal("retried after max elapsed time")
+	}
+}
+
+func TestBackoffUnlimitedInterval(t *testing.T) {
+	for _, jitter := range []float64{0, 1} {
+		backoff := gonet.NewBackoff().SetJitter(jitter).SetMaxRetries(-1).SetMaxInterval(0)
+		for _, attempt := range []int{40, 100, 2000} {
+			wait, ok := backoff.NextRetry(attempt, 0, statusResponse(503, ""), nil)
+			if !ok || wait <= 0 || wait > 2*time.Hour {
+				t.Fatalf("jitter %v, attempt %d: NextRetry = %v, %v", jitter, attempt, wait, ok)
+			}
+		}
+	}
+
+	backoff := gonet.NewBackoff().SetJitter(0).SetMaxInterval(0).SetMaxRetries(-1)
+	if wait, _ := backoff.NextRetry(100, 0, statusResponse(503, ""), nil); wait != time.Hour {
+		t.Fatalf("wait = %v, want 1h", wait)
+	}
+}
+
+func TestBackoffRetryAfter(t *testing.T) {
+	backoff := gonet.NewBackoff().SetJitter(0)
+
+	wait, ok := backoff.NextRetry(0, 0, statusResponse(503, "2"), nil)
+	if !ok || wait != 2*time.Second {
+		t.Fatalf("Retry-After: 2 = %v, %v, want 2s, true", wait, ok)
+	}
+
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	wait, ok = backoff.NextRetry(0, 0, statusResponse(503, date), nil)
+	if !ok || wait <= 8*time.Second || wait > 10*time.Second {
+		t.Fatalf("Retry-After: %s = %v, %v", date, wait, ok)
+	}
+
+	if wait, ok := backoff.NextRetry(0, 0, statusResponse(503, "86400"), nil); ok {
+		t.Fatalf("Retry-After: 86400 = %v, true, want no retry", wait)
+	}
+
+	wait, ok = backoff.NextRetry(0, 0, statusResponse(503, "soon"), nil)
+	if !ok || wait != 500*time.Millisecond {
+		t.Fatalf("invalid Retry-After = %v, %v, want 500ms, true", wait, ok)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{io.EOF, true},
+		{io.ErrUnexpectedEOF, true},
+		{gonettest.ErrConnectionReset, true},
+		{&url.Error{Op: "Get", URL: "http://example.com", Err: gonettest.ErrConnectionReset}, true},
+		{context.Canceled, false},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}, false},
+		{fmt.Errorf("wrapped: %w", context.Canceled), false},
+		{fmt.Errorf("plain error"), false},
+	}
+	for _, tt := range tests {
+		if got := gonet.IsRetryableError(tt.err); got != tt.want {
+			t.Errorf("IsRetryableError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRequestRetry(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/data").Times(2).Reply(503, "busy")
+	mock.On("GET", "/data").Once().Reply(200, "ok")
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond))
+	resp, err := req.Do("GET", mock.URL+"/data", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.String()
+	if err != nil || body != "ok" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	if resp.Retries() != 2 {
+		t.Fatalf("Retries() = %d, want 2", resp.Retries())
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestRequestRetryConnectionReset(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/reset").Once().Reset()
+	mock.On("GET", "/reset").Once().Reply(200, "ok")
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond))
+	body, err := req.GET(mock.URL + "/reset").String()
+	if err != nil || body != "ok" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	mock.AssertExpectations(t)
+}
+
+func TestRequestRetryGivesUp(t *testing.T) {
+	mock := gonettest.NewServer()
+	defer mock.Close()
+
+	mock.On("GET", "/busy").ReplyBytes(503, []byte("busy"), http.Header{"Retry-After": {"3600"}})
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetInitialInterval(time.Millisecond).SetMaxRetries(1))
+	start := time.Now()
+	resp, err := req.Do("GET", mock.URL+"/busy", nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != 503 || resp.Retries() != 0 {
+		t.Fatalf("StatusCode() = %d, Retries() = %d", resp.StatusCode(), resp.Retries())
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("waited for Retry-After")
+	}
+}
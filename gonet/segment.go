@@ -0,0 +1,329 @@
+package gonet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 每一段至少 256KB，文件太小时分段没有意义
+const minSegmentSize = 256 * 1024
+
+// 分段下载的进度保存在 fileName.part.segments
+const segmentsSuffix = ".segments"
+
+// 多连接分段下载。
+// 服务器支持 Range 时，把文件分成 connections 段并行下载，写入 fileName.part 中对应的位置，
+// 全部完成并且校验通过后重命名为 fileName；不支持时退回到 Download 单连接下载。
+// 进度汇总所有分段后，通过 OnDownload 注册的回调报告。
+// 每一段中断后，按照重试策略从中断的位置继续；仍然失败时保留 .part，
+// 服务器返回的 ETag 或 Last-Modified 和每一段完成的位置保存在 fileName.part.segments，
+// 下次下载时如果文件没有变化，只下载没有完成的部分
+func (this *Request) ParallelDownload(URL, fileName string, connections int, checksums ...*Checksum) error {
+	return this.ParallelDownloadContext(nil, URL, fileName, connections, checksums...)
+}
+
+func (this *Request) ParallelDownloadContext(ctx context.Context, URL, fileName string, connections int, checksums ...*Checksum) error {
+	if ctx == nil {
+		ctx = this.ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	part := fileName + partSuffix
+	segmentsFile := part + segmentsSuffix
+
+	size, validator, ok, err := this.probeRange(ctx, URL)
+	if err != nil {
+		return err
+	}
+	if n := int(size / minSegmentSize); n < connections {
+		connections = n
+	}
+	if !ok || connections < 2 {
+		os.Remove(segmentsFile)
+		return this.DownloadContext(ctx, URL, fileName, checksums...)
+	}
+
+	state := readSegments(segmentsFile, part, size, validator)
+	flag := os.O_CREATE | os.O_WRONLY
+	if state == nil {
+		state = newSegments(size, validator, connections)
+		flag |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(part, flag, 0666)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		os.Remove(part)
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// 重试由 downloadSegment 负责，每一段的请求不再重试
+	request := this.clone().SetRetryPolicy(NewBackoff().SetMaxRetries(0))
+
+	current := state.done()
+	done := make(chan struct{})
+	reported := make(chan struct{})
+	go func() {
+		defer close(reported)
+		this.reportProgress(&current, size, done)
+	}()
+
+	errs := make(chan error, len(state.Segments))
+	var wg sync.WaitGroup
+	for _, seg := range state.Segments {
+		if seg.finished() {
+			continue
+		}
+
+		wg.Add(1)
+		go func(seg *segment) {
+			defer wg.Done()
+			if err := this.downloadSegment(ctx, request, URL, validator, f, seg, &current); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(seg)
+	}
+	wg.Wait()
+	close(done)
+	<-reported
+	close(errs)
+
+	// 第一个错误是原因，其余的分段是因为 cancel 而停止的
+	err = <-errs
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// 没有校验值时无法判断文件是否变化，不能续传
+		if validator == "" || writeSegments(segmentsFile, state) != nil {
+			os.Remove(part)
+			os.Remove(segmentsFile)
+		}
+		return err
+	}
+
+	return finishDownload(part, segmentsFile, fileName, checksums)
+}
+
+// 用 Range: bytes=0-0 探测服务器是否支持分段下载，返回文件大小和 If-Range 使用的校验值
+func (this *Request) probeRange(ctx context.Context, URL string) (int64, string, bool, error) {
+	hdr := this.headers.Clone()
+	hdr.Set("Accept-Encoding", "identity")
+	hdr.Set("Range", "bytes=0-0")
+
+	resp, err := this.Do("GET", URL, nil, hdr, ctx)
+	if err != nil {
+		return 0, "", false, err
+	}
+	defer resp.Close()
+
+	if resp.StatusCode() != http.StatusPartialContent {
+		return 0, "", false, nil
+	}
+
+	_, _, size, ok := parseContentRange(resp.Header())
+	if !ok || size <= 0 {
+		return 0, "", false, nil
+	}
+
+	return size, validatorOf(resp.Header()), true, nil
+}
+
+// 下载 seg 中没有完成的部分，中断后按照重试策略从中断的位置继续。
+// request 是不重试的副本，每一段只按这里的策略重试
+func (this *Request) downloadSegment(ctx context.Context, request *Request, URL, validator string, f *os.File, seg *segment, current *int64) error {
+	policy := this.getRetryPolicy()
+	before := time.Now()
+	for attempt := 0; ; attempt++ {
+		n, resp, err := request.downloadRange(ctx, URL, validator, f, seg.Start+seg.Done, seg.End, current)
+		seg.Done += n
+		if err == nil {
+			return nil
+		}
+
+		// resp 不为 nil 时是状态码错误，按状态码和 Retry-After 决定是否重试
+		var wait time.Duration
+		var ok bool
+		if resp != nil {
+			wait, ok = policy.NextRetry(attempt, time.Since(before), resp, nil)
+		} else {
+			wait, ok = policy.NextRetry(attempt, time.Since(before), nil, err)
+		}
+		if !ok {
+			return err
+		}
+
+		this.logf(WARN, "retries %d after %s: bytes %d-%d: %s", attempt+1, wait, seg.Start+seg.Done, seg.End, err.Error())
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// 下载 [start, end] 写入 f，返回写入的字节数。服务器没有返回 206 时，返回它的响应，响应体已经关闭
+func (this *Request) downloadRange(ctx context.Context, URL, validator string, f *os.File, start, end int64, current *int64) (int64, *http.Response, error) {
+	hdr := this.headers.Clone()
+	hdr.Set("Accept-Encoding", "identity")
+	hdr.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if validator != "" {
+		hdr.Set("If-Range", validator)
+	}
+
+	resp, err := this.Do("GET", URL, nil, hdr, ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if resp.StatusCode() != http.StatusPartialContent {
+		resp.Close()
+		return 0, resp.Raw(), fmt.Errorf("gonet: download %s: bytes %d-%d: %s", URL, start, end, resp.Raw().Status)
+	}
+	if s, e, _, ok := parseContentRange(resp.Header()); !ok || s != start || e != end {
+		resp.Close()
+		return 0, nil, fmt.Errorf("gonet: download %s: unexpected Content-Range %q", URL, resp.Header().Get("Content-Range"))
+	}
+
+	var written int64
+	err = resp.stream(func(r io.Reader) error {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if _, err := f.WriteAt(buf[:n], start+written); err != nil {
+					return err
+				}
+				written += int64(n)
+				atomic.AddInt64(current, int64(n))
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+	if err == nil && written != end-start+1 {
+		err = fmt.Errorf("%w: bytes %d-%d got %d bytes", ErrIncompleteDownload, start, end, written)
+	}
+
+	return written, nil, err
+}
+
+// 分段下载的进度，保存在 fileName.part.segments 中
+type segments struct {
+	Validator string     `json:"validator"`
+	Size      int64      `json:"size"`
+	Segments  []*segment `json:"segments"`
+}
+
+// [Start, End] 中已经写入了 Done 个字节
+type segment struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+	Done  int64 `json:"done"`
+}
+
+func (this *segment) finished() bool {
+	return this.Start+this.Done > this.End
+}
+
+// 分成 n 段，最后一段包含除不尽的部分
+func newSegments(size int64, validator string, n int) *segments {
+	this := &segments{Validator: validator, Size: size}
+	segmentSize := size / int64(n)
+	for i := 0; i < n; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+		if i == n-1 {
+			end = size - 1
+		}
+		this.Segments = append(this.Segments, &segment{Start: start, End: end})
+	}
+
+	return this
+}
+
+func (this *segments) done() int64 {
+	var done int64
+	for _, seg := range this.Segments {
+		done += seg.Done
+	}
+	return done
+}
+
+// 读取上次保存的进度。文件在服务器上变化了，或者 .part 不完整时返回 nil
+func readSegments(fileName, part string, size int64, validator string) *segments {
+	if validator == "" {
+		return nil
+	}
+	info, err := os.Stat(part)
+	if err != nil || info.Size() != size {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil
+	}
+	state := &segments{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil
+	}
+	if state.Validator != validator || state.Size != size || len(state.Segments) == 0 {
+		return nil
+	}
+	for _, seg := range state.Segments {
+		if seg.Start < 0 || seg.End >= size || seg.Done < 0 || seg.Start+seg.Done > seg.End+1 {
+			return nil
+		}
+	}
+
+	return state
+}
+
+func writeSegments(fileName string, state *segments) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(fileName, data, 0666)
+}
+
+// 每隔 downloadProgressInterval 报告一次所有分段的进度，done 被关闭时报告最后一次
+func (this *Request) reportProgress(current *int64, total int64, done chan struct{}) {
+	if len(this.downloadCallbacks) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(this.downloadProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			this.handleOnDownload(atomic.LoadInt64(current), total)
+			return
+		case <-ticker.C:
+			this.handleOnDownload(atomic.LoadInt64(current), total)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package gonet_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhuomouren/gohelpers/gonet"
+)
+
+// 4 段以上，并且不能被 4 整除
+var segmentContent = func() []byte {
+	data := make([]byte, 4*256*1024+123)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}()
+
+// 支持 Range 的文件服务器，记录每个请求的 Range。
+// fail 不为 nil 时，它返回的状态码不为 0 的请求直接返回这个状态码
+type segmentServer struct {
+	*httptest.Server
+	ranges []string
+	fail   func(rangeHeader string) int
+	lock   sync.Mutex
+}
+
+func newSegmentServer(acceptRanges bool) *segmentServer {
+	this := &segmentServer{}
+	modtime := time.Unix(1600000000, 0)
+	this.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		this.lock.Lock()
+		this.ranges = append(this.ranges, rangeHeader)
+		fail := this.fail
+		this.lock.Unlock()
+
+		if fail != nil {
+			if code := fail(rangeHeader); code != 0 {
+				w.WriteHeader(code)
+				return
+			}
+		}
+
+		if !acceptRanges {
+			w.Write(segmentContent)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "file", modtime, bytes.NewReader(segmentContent))
+	}))
+
+	return this
+}
+
+func (this *segmentServer) Ranges() []string {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return append([]string{}, this.ranges...)
+}
+
+func (this *segmentServer) SetFail(fail func(rangeHeader string) int) {
+	this.lock.Lock()
+	this.ranges = nil
+	this.fail = fail
+	this.lock.Unlock()
+}
+
+func assertSegmentDownloaded(t *testing.T, fileName string) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, segmentContent) {
+		t.Fatalf("downloaded %d bytes, want %d", len(data), len(segmentContent))
+	}
+	for _, name := range []string{fileName + ".part", fileName + ".part.segments"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s was not removed", name)
+		}
+	}
+}
+
+func TestParallelDownload(t *testing.T) {
+	server := newSegmentServer(true)
+	defer server.Close()
+
+	var lock sync.Mutex
+	var current, total int64
+	req := gonet.NewRequest()
+	req.OnDownload(func(c, t int64) {
+		lock.Lock()
+		current, total = c, t
+		lock.Unlock()
+	})
+
+	sum := sha256.Sum256(segmentContent)
+	fileName := filepath.Join(t.TempDir(), "file")
+	if err := req.ParallelDownload(server.URL, fileName, 4, gonet.SHA256Checksum(hex.EncodeToString(sum[:]))); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentDownloaded(t, fileName)
+
+	size := int64(len(segmentContent))
+	if current != size || total != size {
+		t.Fatalf("progress = %d/%d, want %d/%d", current, total, size, size)
+	}
+
+	// 探测一次，然后 4 段，最后一段包含除不尽的部分
+	ranges := server.Ranges()
+	want := map[string]bool{"bytes=0-0": true}
+	segmentSize := size / 4
+	for i := int64(0); i < 4; i++ {
+		end := (i+1)*segmentSize - 1
+		if i == 3 {
+			end = size - 1
+		}
+		want[fmt.Sprintf("bytes=%d-%d", i*segmentSize, end)] = true
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("ranges = %q", ranges)
+	}
+	for _, r := range ranges {
+		if !want[r] {
+			t.Fatalf("unexpected range %q in %q", r, ranges)
+		}
+	}
+}
+
+func TestParallelDownloadFallback(t *testing.T) {
+	server := newSegmentServer(false)
+	defer server.Close()
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	if err := gonet.NewRequest().ParallelDownload(server.URL, fileName, 4); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentDownloaded(t, fileName)
+
+	// 探测之后使用 Download 单连接下载
+	if ranges := server.Ranges(); len(ranges) != 2 || ranges[0] != "bytes=0-0" || ranges[1] != "" {
+		t.Fatalf("ranges = %q", ranges)
+	}
+}
+
+func TestParallelDownloadMinSegmentSize(t *testing.T) {
+	server := newSegmentServer(true)
+	defer server.Close()
+
+	// 每一段至少 256KB，最多分成 4 段
+	fileName := filepath.Join(t.TempDir(), "file")
+	if err := gonet.NewRequest().ParallelDownload(server.URL, fileName, 100); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentDownloaded(t, fileName)
+	if n := len(server.Ranges()); n != 1+4 {
+		t.Fatalf("%d requests, want 5", n)
+	}
+}
+
+func TestParallelDownloadChecksumMismatch(t *testing.T) {
+	server := newSegmentServer(true)
+	defer server.Close()
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	err := gonet.NewRequest().ParallelDownload(server.URL, fileName, 4, gonet.SHA256Checksum("00"))
+	if !errors.Is(err, gonet.ErrChecksumMismatch) {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+	for _, name := range []string{fileName, fileName + ".part", fileName + ".part.segments"} {
+		if _, err := os.Stat(name); !os.IsNotExist(err) {
+			t.Fatalf("%s exists", name)
+		}
+	}
+}
+
+func TestParallelDownloadRetriesOnce(t *testing.T) {
+	server := newSegmentServer(true)
+	defer server.Close()
+
+	size := int64(len(segmentContent))
+	last := fmt.Sprintf("bytes=%d-%d", size/4*3, size-1)
+	server.SetFail(func(rangeHeader string) int {
+		if rangeHeader == last {
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	})
+
+	req := gonet.NewRequest().SetRetryPolicy(gonet.NewBackoff().SetMaxRetries(3).SetInitialInterval(time.Millisecond))
+	fileName := filepath.Join(t.TempDir(), "file")
+	if err := req.ParallelDownload(server.URL, fileName, 4); err == nil {
+		t.Fatal("download with a failing segment returned no error")
+	}
+
+	// 每一段的请求不再重试，一共请求 1 + 3 次
+	count := 0
+	for _, r := range server.Ranges() {
+		if r == last {
+			count++
+		}
+	}
+	if count != 4 {
+		t.Fatalf("failing segment requested %d times, want 4", count)
+	}
+}
+
+func TestParallelDownloadResume(t *testing.T) {
+	server := newSegmentServer(true)
+	defer server.Close()
+
+	// 最后一段在其他分段完成后失败
+	size := int64(len(segmentContent))
+	last := fmt.Sprintf("bytes=%d-%d", size/4*3, size-1)
+	server.SetFail(func(rangeHeader string) int {
+		if rangeHeader == last {
+			time.Sleep(300 * time.Millisecond)
+			return http.StatusForbidden
+		}
+		return 0
+	})
+
+	fileName := filepath.Join(t.TempDir(), "file")
+	req := gonet.NewRequest()
+	if err := req.ParallelDownload(server.URL, fileName, 4); err == nil {
+		t.Fatal("download with a failing segment returned no error")
+	}
+	for _, name := range []string{fileName + ".part", fileName + ".part.segments"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatalf("%s was not kept: %v", name, err)
+		}
+	}
+
+	server.SetFail(nil)
+	if err := req.ParallelDownload(server.URL, fileName, 4); err != nil {
+		t.Fatal(err)
+	}
+	assertSegmentDownloaded(t, fileName)
+
+	// 只下载上次没有完成的部分
+	var requested int64
+	for _, r := range server.Ranges()[1:] {
+		var start, end int64
+		if _, err := fmt.Sscanf(r, "bytes=%d-%d", &start, &end); err != nil {
+			t.Fatalf("range %q: %v", r, err)
+		}
+		requested += end - start + 1
+	}
+	if requested >= size {
+		t.Fatalf("requested %d bytes again, want less than %d", requested, size)
+	}
+}
@@ -0,0 +1,271 @@
+package gonet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrBodyTooLarge = errors.New("gonet: response body too large")
+	// 在 EachLine 等方法的回调中返回，结束读取，不会作为错误返回
+	ErrStopStream = errors.New("gonet: stop stream")
+)
+
+// Server-Sent Events 中的一个事件
+type Event struct {
+	ID    string // 最近一次设置的 id，没有设置时为空
+	Event string // 事件类型，默认是 message
+	Data  string
+	Retry time.Duration // 服务器要求的重连间隔，没有设置时为 0
+}
+
+// 返回响应体，读取的长度超过 maxBodySize 时返回 ErrBodyTooLarge。
+// 调用者负责关闭，之后再调用 Bytes 等方法会返回 ErrBodyConsumed
+func (this *Response) Body() (io.ReadCloser, error) {
+	var body io.ReadCloser
+	this.once.Do(func() {
+		this.consumed = true
+		body = &streamBody{
+			Reader:   newMaxBytesReader(this.response.Body, this.maxBodySize),
+			response: this,
+		}
+	})
+	if body == nil {
+		return nil, ErrBodyConsumed
+	}
+
+	return body, nil
+}
+
+// 每次从连接读到数据就调用 f，chunk 在 f 返回后会被复用
+func (this *Response) EachChunk(f func(chunk []byte) error) error {
+	return this.stream(func(r io.Reader) error {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if err := f(buf[:n]); err != nil {
+					return stopStream(err)
+				}
+			}
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// 逐行读取，line 不包含换行符
+func (this *Response) EachLine(f func(line string) error) error {
+	return this.scan(bufio.ScanLines, func(line []byte) error {
+		return f(string(line))
+	})
+}
+
+// 读取 NDJSON（每行一个 JSON），跳过空行
+func (this *Response) EachJSON(f func(data json.RawMessage) error) error {
+	n := 0
+	return this.scan(bufio.ScanLines, func(line []byte) error {
+		n++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			return nil
+		}
+		if !json.Valid(line) {
+			return fmt.Errorf("gonet: invalid JSON at line %d", n)
+		}
+
+		return f(json.RawMessage(append([]byte(nil), line...)))
+	})
+}
+
+// 读取 Server-Sent Events，见 https://html.spec.whatwg.org/multipage/server-sent-events.html
+func (this *Response) EachEvent(f func(event *Event) error) error {
+	var id, eventType string
+	var data strings.Builder
+	var retry time.Duration
+	hasData := false
+
+	first := true
+	return this.scan(scanEventLines, func(line []byte) error {
+		if first {
+			line = bytes.TrimPrefix(line, []byte("\xef\xbb\xbf"))
+			first = false
+		}
+
+		if len(line) == 0 {
+			if !hasData {
+				eventType = ""
+				return nil
+			}
+
+			event := &Event{ID: id, Event: eventType, Data: strings.TrimSuffix(data.String(), "\n"), Retry: retry}
+			if event.Event == "" {
+				event.Event = "message"
+			}
+			eventType, hasData = "", false
+			data.Reset()
+
+			return f(event)
+		}
+		if line[0] == ':' {
+			return nil
+		}
+
+		field, value := string(line), ""
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = string(line[:i]), strings.TrimPrefix(string(line[i+1:]), " ")
+		}
+
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+			hasData = true
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				id = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 64); err == nil {
+				retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+
+		return nil
+	})
+}
+
+// 用 split 分割响应体，每一段的长度不能超过 maxBodySize
+func (this *Response) scan(split bufio.SplitFunc, f func(token []byte) error) error {
+	return this.stream(func(r io.Reader) error {
+		max := int(^uint(0) >> 1)
+		if this.maxBodySize > 0 && this.maxBodySize < int64(max) {
+			max = int(this.maxBodySize)
+		}
+
+		// 初始缓冲区比 max 大时，max 不起作用
+		size := 4096
+		if size > max {
+			size = max
+		}
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, size), max)
+		scanner.Split(split)
+		for scanner.Scan() {
+			if err := f(scanner.Bytes()); err != nil {
+				return stopStream(err)
+			}
+		}
+		if scanner.Err() == bufio.ErrTooLong {
+			return ErrBodyTooLarge
+		}
+
+		return scanner.Err()
+	})
+}
+
+func stopStream(err error) error {
+	if err == ErrStopStream {
+		return nil
+	}
+
+	return err
+}
+
+// 和 bufio.ScanLines 相同，但是也把单独的 \r 当作换行符
+func scanEventLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// \r 在最后时，需要看下一个字节是不是 \n
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
+
+type streamBody struct {
+	io.Reader
+	response *Response
+}
+
+func (this *streamBody) Read(p []byte) (int, error) {
+	n, err := this.Reader.Read(p)
+	if err != nil && err != io.EOF && err != ErrBodyTooLarge {
+		err = this.response.contextErr(err)
+	}
+
+	return n, err
+}
+
+func (this *streamBody) Close() error {
+	return this.response.response.Body.Close()
+}
+
+// 和 http.MaxBytesReader 类似，超过 n 字节时返回 ErrBodyTooLarge
+type maxBytesReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func newMaxBytesReader(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+
+	return &maxBytesReader{r: r, n: n}
+}
+
+func (this *maxBytesReader) Read(p []byte) (int, error) {
+	if this.err != nil {
+		return 0, this.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	// 多读一个字节，用来判断是否超过了限制
+	if int64(len(p))-1 > this.n {
+		p = p[:this.n+1]
+	}
+	n, err := this.r.Read(p)
+	if int64(n) <= this.n {
+		this.n -= int64(n)
+		this.err = err
+		return n, err
+	}
+
+	n = int(this.n)
+	this.n = 0
+	this.err = ErrBodyTooLarge
+	return n, this.err
+}
@@ -0,0 +1,188 @@
+package gonet
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+var ErrCertificatePin = errors.New("gonet: certificate does not match any pinned public key")
+
+// 证书公钥（SubjectPublicKeyInfo）的 sha256 值，base64 编码，和 HPKP 的 pin-sha256 格式相同。
+// 可以用下面的命令得到：
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// 使用 config 作为 TLS 配置的基础，其他 TLS 设置会覆盖 config 中对应的字段
+func (this *Request) SetTLSConfig(config *tls.Config) *Request {
+	this.tlsConfig = config.Clone()
+	this.resetClient()
+	return this
+}
+
+// 验证服务器证书使用的根证书，默认使用系统的根证书
+func (this *Request) SetRootCAs(pool *x509.CertPool) *Request {
+	this.tlsOptions.rootCAs = pool
+	this.resetClient()
+	return this
+}
+
+// 添加 PEM 格式的根证书文件
+func (this *Request) AddRootCAFile(fileName string) *Request {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		this.logf(ERROR, "read root ca [%s] error: %s", fileName, err.Error())
+		return this
+	}
+
+	return this.AddRootCA(data)
+}
+
+// 添加 PEM 格式的根证书
+func (this *Request) AddRootCA(pemCerts []byte) *Request {
+	if this.tlsOptions.rootCAs == nil {
+		this.tlsOptions.rootCAs = x509.NewCertPool()
+	}
+	if !this.tlsOptions.rootCAs.AppendCertsFromPEM(pemCerts) {
+		this.logf(ERROR, "no valid root ca certificate found")
+		return this
+	}
+
+	this.resetClient()
+	return this
+}
+
+// mTLS 使用的客户端证书
+func (this *Request) SetClientCertificate(certs ...tls.Certificate) *Request {
+	this.tlsOptions.certificates = certs
+	this.resetClient()
+	return this
+}
+
+func (this *Request) SetClientCertificateFile(certFile, keyFile string) *Request {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		this.logf(ERROR, "load client certificate [%s] error: %s", certFile, err.Error())
+		return this
+	}
+
+	return this.SetClientCertificate(cert)
+}
+
+// 允许的 TLS 版本，例如 tls.VersionTLS12、tls.VersionTLS13，为 0 时使用默认值
+func (this *Request) SetTLSVersion(min, max uint16) *Request {
+	this.tlsOptions.minVersion = min
+	this.tlsOptions.maxVersion = max
+	this.resetClient()
+	return this
+}
+
+// TLS 1.2 及以下版本使用的加密套件，TLS 1.3 的加密套件不能设置
+func (this *Request) SetCipherSuites(suites ...uint16) *Request {
+	this.tlsOptions.cipherSuites = suites
+	this.resetClient()
+	return this
+}
+
+// 覆盖 SNI 中的主机名，也用来验证服务器证书。对所有请求都有效
+func (this *Request) SetServerName(serverName string) *Request {
+	this.tlsOptions.serverName = serverName
+	this.resetClient()
+	return this
+}
+
+// 只信任公钥 SPKIHash 值在 pins 中的证书链，验证通过的证书链中任意一个证书匹配即可。
+// 跳过证书验证时也会检查，但这时没有验证过的证书链，只检查服务器自己的证书
+func (this *Request) PinCertificate(pins ...string) *Request {
+	this.tlsOptions.pins = append(this.tlsOptions.pins, pins...)
+	this.resetClient()
+	return this
+}
+
+type tlsOptions struct {
+	rootCAs      *x509.CertPool
+	certificates []tls.Certificate
+	minVersion   uint16
+	maxVersion   uint16
+	cipherSuites []uint16
+	serverName   string
+	pins         []string
+}
+
+func (this *Request) newTLSConfig() *tls.Config {
+	config := &tls.Config{}
+	if this.tlsConfig != nil {
+		config = this.tlsConfig.Clone()
+	}
+
+	opts := this.tlsOptions
+	// 建立TLS连接的时候，是否验证服务器的证书
+	if this.insecureTLSSkipVerify {
+		config.InsecureSkipVerify = true
+	}
+	if opts.rootCAs != nil {
+		config.RootCAs = opts.rootCAs
+	}
+	if len(opts.certificates) > 0 {
+		config.Certificates = opts.certificates
+	}
+	if opts.minVersion != 0 {
+		config.MinVersion = opts.minVersion
+	}
+	if opts.maxVersion != 0 {
+		config.MaxVersion = opts.maxVersion
+	}
+	if len(opts.cipherSuites) > 0 {
+		config.CipherSuites = opts.cipherSuites
+	}
+	if opts.serverName != "" {
+		config.ServerName = opts.serverName
+	}
+	if len(opts.pins) > 0 {
+		pins := make(map[string]bool, len(opts.pins))
+		for _, pin := range opts.pins {
+			pins[pin] = true
+		}
+
+		verify := config.VerifyConnection
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			if verify != nil {
+				if err := verify(cs); err != nil {
+					return err
+				}
+			}
+			if matchPins(cs, pins) {
+				return nil
+			}
+			return fmt.Errorf("%w: %s", ErrCertificatePin, cs.ServerName)
+		}
+	}
+
+	return config
+}
+
+// PeerCertificates 是服务器发送的，没有经过验证，服务器可以在里面附带任意的证书，
+// 所以只检查验证过的证书链；没有验证时只检查服务器自己的证书
+func matchPins(cs tls.ConnectionState, pins map[string]bool) bool {
+	if len(cs.VerifiedChains) == 0 {
+		return len(cs.PeerCertificates) > 0 && pins[SPKIHash(cs.PeerCertificates[0])]
+	}
+
+	for _, chain := range cs.VerifiedChains {
+		for _, cert := range chain {
+			if pins[SPKIHash(cert)] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,159 @@
+package gonet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// parent 为 nil 时生成自签名的证书
+func newTestCert(t *testing.T, name string, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		tmpl.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1)}
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCert{cert: cert, key: key}
+}
+
+func (this *testCert) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: this.cert.Raw})
+}
+
+// 使用 leaf 和 chain 中的证书启动 TLS 服务器
+func newPinServer(leaf *testCert, chain ...*testCert) *httptest.Server {
+	certificate := tls.Certificate{Certificate: [][]byte{leaf.cert.Raw}, PrivateKey: leaf.key}
+	for _, c := range chain {
+		certificate.Certificate = append(certificate.Certificate, c.cert.Raw)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{certificate}}
+	srv.StartTLS()
+
+	return srv
+}
+
+func TestTLSVerifyByDefault(t *testing.T) {
+	ca := newTestCert(t, "ca", true, nil)
+	srv := newPinServer(newTestCert(t, "leaf", false, ca))
+	defer srv.Close()
+
+	if _, err := NewRequest().GET(srv.URL).String(); err == nil {
+		t.Fatal("untrusted certificate was accepted")
+	}
+	if body, err := NewRequest().AddRootCA(ca.pem()).GET(srv.URL).String(); err != nil || body != "ok" {
+		t.Fatalf("body = %q, %v", body, err)
+	}
+	if body, err := NewRequest().EnableInsecureTLSSkipVerify().GET(srv.URL).String(); err != nil || body != "ok" {
+		t.Fatalf("insecure: body = %q, %v", body, err)
+	}
+}
+
+func TestPinCertificate(t *testing.T) {
+	ca := newTestCert(t, "ca", true, nil)
+	leaf := newTestCert(t, "leaf", false, ca)
+	srv := newPinServer(leaf)
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		req  *Request
+		ok   bool
+	}{
+		{"pin ca", NewRequest().AddRootCA(ca.pem()).PinCertificate(SPKIHash(ca.cert)), true},
+		{"pin leaf", NewRequest().AddRootCA(ca.pem()).PinCertificate("AAAA", SPKIHash(leaf.cert)), true},
+		{"pin mismatch", NewRequest().AddRootCA(ca.pem()).PinCertificate("AAAA"), false},
+		{"insecure pin leaf", NewRequest().EnableInsecureTLSSkipVerify().PinCertificate(SPKIHash(leaf.cert)), true},
+		// 没有验证时 CA 不在证书链中
+		{"insecure pin ca", NewRequest().EnableInsecureTLSSkipVerify().PinCertificate(SPKIHash(ca.cert)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.req.GET(srv.URL).String()
+			if tt.ok && err != nil {
+				t.Fatal(err)
+			}
+			if !tt.ok && !errors.Is(err, ErrCertificatePin) {
+				t.Fatalf("err = %v, want ErrCertificatePin", err)
+			}
+		})
+	}
+}
+
+// 服务器在自己的证书链后面附带被 pin 的证书，不能通过检查
+func TestPinCertificateAppendedCert(t *testing.T) {
+	pinned := newTestCert(t, "pinned ca", true, nil)
+	pinnedLeaf := newTestCert(t, "pinned leaf", false, pinned)
+
+	t.Run("insecure", func(t *testing.T) {
+		attacker := newTestCert(t, "attacker", false, nil)
+		srv := newPinServer(attacker, pinnedLeaf, pinned)
+		defer srv.Close()
+
+		req := NewRequest().EnableInsecureTLSSkipVerify().PinCertificate(SPKIHash(pinned.cert), SPKIHash(pinnedLeaf.cert))
+		if _, err := req.GET(srv.URL).String(); !errors.Is(err, ErrCertificatePin) {
+			t.Fatalf("err = %v, want ErrCertificatePin", err)
+		}
+	})
+
+	t.Run("mis-issued", func(t *testing.T) {
+		// 另一个受信任的 CA 签发的证书
+		other := newTestCert(t, "other ca", true, nil)
+		srv := newPinServer(newTestCert(t, "mis-issued", false, other), pinned)
+		defer srv.Close()
+
+		roots := x509.NewCertPool()
+		roots.AddCert(other.cert)
+		roots.AddCert(pinned.cert)
+		req := NewRequest().SetRootCAs(roots).PinCertificate(SPKIHash(pinned.cert))
+		if _, err := req.GET(srv.URL).String(); !errors.Is(err, ErrCertificatePin) {
+			t.Fatalf("err = %v, want ErrCertificatePin", err)
+		}
+	})
+}
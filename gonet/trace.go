@@ -0,0 +1,232 @@
+package gonet
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing 是一次 HTTP 往返的耗时。没有发生的阶段为 0，例如复用连接时没有 DNS、Connect 和 TLS
+type Timing struct {
+	DNS        time.Duration // 域名解析
+	Connect    time.Duration // 建立 TCP 连接
+	TLS        time.Duration // TLS 握手
+	TTFB       time.Duration // 从开始请求到收到响应的第一个字节，包括前面的阶段
+	Transfer   time.Duration // 读取响应体，响应体读完或者关闭后才有值
+	Total      time.Duration // 从开始请求到响应体读完或者关闭
+	Reused     bool          // 是否复用了之前的连接
+	WasIdle    bool          // 复用的连接是否来自空闲连接池
+	IdleTime   time.Duration // 复用前连接空闲的时间
+	RemoteAddr string
+}
+
+// Metrics 是一次 HTTP 往返的记录，重试和跳转的每次请求都会单独记录
+type Metrics struct {
+	Method     string
+	URL        string
+	StatusCode int   // 请求出错时为 0
+	Err        error // 请求或者读取响应体时的错误
+	Timing     Timing
+}
+
+// MetricsSink 接收请求的记录，可能在多个 goroutine 中同时调用。
+// 写到 golog 的 MetricsSink 见 gonetlog.MetricsSink
+type MetricsSink interface {
+	Observe(m *Metrics)
+}
+
+// MetricsSinkFunc 把函数转换成 MetricsSink
+type MetricsSinkFunc func(m *Metrics)
+
+func (f MetricsSinkFunc) Observe(m *Metrics) {
+	f(m)
+}
+
+// 用 httptrace 记录每次请求的耗时，sink 不为 nil 时在响应体读完或者关闭后把记录交给 sink。
+// 通过 Request 发送的请求，最后一次请求的耗时可以用 Response.Timing 读取
+func TraceMiddleware(sink MetricsSink) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			tracer := &tracer{
+				metrics: Metrics{Method: req.Method, URL: req.URL.String()},
+				sink:    sink,
+				start:   time.Now(),
+				lock:    &sync.Mutex{},
+			}
+			if recorder, ok := req.Context().Value(timingKey{}).(*timingRecorder); ok {
+				recorder.set(tracer)
+			}
+
+			req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.clientTrace()))
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				tracer.finish(0, err)
+				return nil, err
+			}
+
+			if resp.Body == nil || resp.Body == http.NoBody {
+				tracer.finish(resp.StatusCode, nil)
+				return resp, nil
+			}
+
+			resp.Body = &traceBody{ReadCloser: resp.Body, tracer: tracer, statusCode: resp.StatusCode}
+			return resp, nil
+		})
+	}
+}
+
+type tracer struct {
+	metrics      Metrics
+	sink         MetricsSink
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	firstByte    time.Time
+	done         bool
+	lock         *sync.Mutex
+}
+
+func (this *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			this.lock.Lock()
+			this.dnsStart = time.Now()
+			this.lock.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			this.lock.Lock()
+			if !this.dnsStart.IsZero() {
+				this.metrics.Timing.DNS = time.Since(this.dnsStart)
+			}
+			this.lock.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			this.lock.Lock()
+			// 同时尝试多个地址时，从第一次开始算起
+			if this.connectStart.IsZero() {
+				this.connectStart = time.Now()
+			}
+			this.lock.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			this.lock.Lock()
+			if err == nil && !this.connectStart.IsZero() {
+				this.metrics.Timing.Connect = time.Since(this.connectStart)
+			}
+			this.lock.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			this.lock.Lock()
+			this.tlsStart = time.Now()
+			this.lock.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			this.lock.Lock()
+			if !this.tlsStart.IsZero() {
+				this.metrics.Timing.TLS = time.Since(this.tlsStart)
+			}
+			this.lock.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			this.lock.Lock()
+			this.metrics.Timing.Reused = info.Reused
+			this.metrics.Timing.WasIdle = info.WasIdle
+			this.metrics.Timing.IdleTime = info.IdleTime
+			if info.Conn != nil {
+				this.metrics.Timing.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+			this.lock.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			this.lock.Lock()
+			this.firstByte = time.Now()
+			this.metrics.Timing.TTFB = this.firstByte.Sub(this.start)
+			this.lock.Unlock()
+		},
+	}
+}
+
+// 请求结束，只有第一次调用有效
+func (this *tracer) finish(statusCode int, err error) {
+	this.lock.Lock()
+	if this.done {
+		this.lock.Unlock()
+		return
+	}
+	this.done = true
+
+	now := time.Now()
+	this.metrics.StatusCode = statusCode
+	this.metrics.Err = err
+	this.metrics.Timing.Total = now.Sub(this.start)
+	if !this.firstByte.IsZero() {
+		this.metrics.Timing.Transfer = now.Sub(this.firstByte)
+	}
+	metrics := this.metrics
+	this.lock.Unlock()
+
+	if this.sink != nil {
+		this.sink.Observe(&metrics)
+	}
+}
+
+func (this *tracer) timing() Timing {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.metrics.Timing
+}
+
+type traceBody struct {
+	io.ReadCloser
+	tracer     *tracer
+	statusCode int
+}
+
+func (this *traceBody) Read(p []byte) (int, error) {
+	n, err := this.ReadCloser.Read(p)
+	if err == io.EOF {
+		this.tracer.finish(this.statusCode, nil)
+	} else if err != nil {
+		this.tracer.finish(this.statusCode, err)
+	}
+
+	return n, err
+}
+
+func (this *traceBody) Close() error {
+	err := this.ReadCloser.Close()
+	this.tracer.finish(this.statusCode, nil)
+	return err
+}
+
+type timingKey struct{}
+
+// 记录一次 Do 中最后一次请求的 tracer
+type timingRecorder struct {
+	tracer *tracer
+	lock   sync.Mutex
+}
+
+func withTimingRecorder(ctx context.Context) (context.Context, *timingRecorder) {
+	recorder := &timingRecorder{}
+	return context.WithValue(ctx, timingKey{}, recorder), recorder
+}
+
+func (this *timingRecorder) set(t *tracer) {
+	this.lock.Lock()
+	this.tracer = t
+	this.lock.Unlock()
+}
+
+func (this *timingRecorder) get() *tracer {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+
+	return this.tracer
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"net/url"
 	"path"
-	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -55,69 +54,23 @@ func (this *GoURL) Join(str ...string) string {
 	return this.Clean(strings.Join(str, "/"))
 }
 
-// 相对路径转绝对路径
+// 相对路径转绝对路径，按 RFC 3986 5.2 解析
 func (this *GoURL) AbsoluteURL(rel, base string) (string, error) {
+	rel = strings.TrimSpace(rel)
 	if rel == "" || strings.HasPrefix(rel, "#") {
 		return "", errors.New("Can't start with #")
 	}
 
-	if strings.HasPrefix(rel, "//") {
-		if strings.HasPrefix(base, "https") {
-			return "https:" + rel, nil
-		} else {
-			return "http:" + rel, nil
-		}
-	}
-
-	u, err := url.Parse(rel)
+	relURL, err := url.Parse(rel)
 	if err != nil {
 		return "", err
 	}
-
-	if u.IsAbs() {
-		return rel, nil
-	}
-
-	if rel[0] == '#' || rel[0] == '?' {
-		return base + rel, nil
-	}
-
-	u, err = url.Parse(base)
+	baseURL, err := url.Parse(base)
 	if err != nil {
 		return "", err
 	}
-	path := u.Path
-	if filepath.Ext(u.Path) != "" {
-		re, _ := regexp.Compile("/[^/]*$")
-		path = re.ReplaceAllString(u.Path, "")
-	}
-
-	var full string
-	if rel[0] == '/' {
-		full = u.Host + rel
-	} else {
-		full = u.Host + path + "/" + rel
-	}
-
-	var dst []string
-	src := strings.Split(full, "/")
-	for _, elem := range src {
-		switch elem {
-		case ".":
-			// drop
-		case "..":
-			if len(dst) > 0 {
-				dst = dst[:len(dst)-1]
-			}
-		default:
-			dst = append(dst, elem)
-		}
-	}
-	if last := src[len(src)-1]; last == "." || last == ".." {
-		dst = append(dst, "")
-	}
 
-	return u.Scheme + "://" + strings.Replace(strings.TrimLeft(strings.Join(dst, "/"), "/"), "//", "/", -1), nil
+	return baseURL.ResolveReference(relURL).String(), nil
 }
 
 // isDomainName checks if a string is a presentation-format domain name
@@ -2,7 +2,6 @@ package gospider
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +34,11 @@ var (
 
 type VisitCallback func(url, html string)
 
+type visitCallback struct {
+	pattern *gonet.URLPattern
+	f       VisitCallback
+}
+
 // 已经采集过的 URL，将不会放入队列
 type VisitedCallback func(url string) bool
 
@@ -45,8 +49,8 @@ type GoSpider struct {
 	proxy            string
 	queue            *goqueue.Queue
 	queueDataPath    string
-	urlsRule         []string
-	visitCallbacks   map[string]VisitCallback
+	urlPatterns      *gonet.URLPatternSet
+	visitCallbacks   map[string]*visitCallback
 	headerMap        map[string]string
 	visitedCallbacks []VisitedCallback
 	visitedUrls      map[string]bool
@@ -75,7 +79,8 @@ func New(name, url string) *GoSpider {
 		status: StatusPending,
 	}
 
-	this.visitCallbacks = make(map[string]VisitCallback)
+	this.urlPatterns = gonet.NewURLPatternSet()
+	this.visitCallbacks = make(map[string]*visitCallback)
 	this.headerMap = map[string]string{}
 	this.visitedUrls = make(map[string]bool)
 	this.visitedCallbacks = make([]VisitedCallback, 0)
@@ -93,6 +98,7 @@ func New(name, url string) *GoSpider {
 	return this
 }
 
+// 强制使用指定的编码，默认自动检测
 func (this *GoSpider) Charset(charset string) *GoSpider {
 	this.charset = charset
 	return this
@@ -127,34 +133,63 @@ func (this *GoSpider) AddHeader(name, val string) *GoSpider {
 	return this
 }
 
+// 采集匹配正则 rule 的链接，rule 可以使用 DeepProcessingRegex 中的写法
 func (this *GoSpider) AddURLRule(rule string) *GoSpider {
-	this.urlsRule = append(this.urlsRule, gohelpers.String.DeepProcessingRegex(rule))
-	return this
+	return this.AddURLPattern("re:" + gohelpers.String.DeepProcessingRegex(rule))
 }
 
 func (this *GoSpider) URLRules(rules []string) *GoSpider {
 	for _, rule := range rules {
-		this.urlsRule = append(this.urlsRule, gohelpers.String.DeepProcessingRegex(rule))
+		this.AddURLRule(rule)
+	}
+
+	return this
+}
+
+// 采集匹配 pattern 的链接，写法见 gonet.URLPattern，例如 *.example.com/book/:id/*.html
+func (this *GoSpider) AddURLPattern(pattern string) *GoSpider {
+	if err := this.urlPatterns.Add(pattern); err != nil {
+		logger.Error("invalid url pattern",
+			logger.String("pattern", pattern),
+			logger.String("error", err.Error()),
+		)
 	}
 
 	return this
 }
 
 func (this *GoSpider) OnVisit(rule string, f VisitCallback) {
+	rule = gohelpers.String.DeepProcessingRegex(rule)
+	pattern, err := gonet.CompileURLPattern("re:" + rule)
+	if err != nil {
+		logger.Error("invalid url rule",
+			logger.String("rule", rule),
+			logger.String("error", err.Error()),
+		)
+		return
+	}
+
 	this.lock.Lock()
-	this.visitCallbacks[gohelpers.String.DeepProcessingRegex(rule)] = f
+	this.visitCallbacks[rule] = &visitCallback{pattern: pattern, f: f}
 	this.lock.Unlock()
 }
 
 func (this *GoSpider) handleOnVisit(url, html string) {
-	for rule, f := range this.visitCallbacks {
-		if this.exactMatch(rule, url) {
+	this.lock.RLock()
+	callbacks := make(map[string]*visitCallback, len(this.visitCallbacks))
+	for rule, callback := range this.visitCallbacks {
+		callbacks[rule] = callback
+	}
+	this.lock.RUnlock()
+
+	for rule, callback := range callbacks {
+		if callback.pattern.Match(url) {
 			logger.Debug("match url",
 				logger.String("url", url),
 				logger.String("match", "yes"),
 				logger.String("rule", rule),
 			)
-			f(url, html)
+			callback.f(url, html)
 		} else {
 			logger.Debug("match url",
 				logger.String("url", url),
@@ -409,10 +444,8 @@ func (this *GoSpider) runOne() error {
 	gohelpers.String.RemoveDuplicate(&urls)
 	// fmt.Println("urls:", urls)
 	for _, link := range urls {
-		for _, urlRule := range this.urlsRule {
-			if this.exactMatch(urlRule, link) {
-				this.putQueue(this.getQueueData(nextDepth, link))
-			}
+		if this.urlPatterns.Match(link) {
+			this.putQueue(this.getQueueData(nextDepth, link))
 		}
 	}
 
@@ -435,17 +468,6 @@ func (this *GoSpider) getHTML(url string) (string, error) {
 	return this.http.GET(url).String()
 }
 
-// 精确匹配
-func (this *GoSpider) exactMatch(regex, data string) bool {
-	re, err := regexp.Compile(`(?i)` + regex)
-	if err != nil {
-		return false
-	}
-
-	str := re.FindString(data)
-	return strings.EqualFold(str, data)
-}
-
 func (this *GoSpider) getQueueData(depth int, url string) string {
 	return fmt.Sprintf("%d%s%s", depth, this.sep, url)
 }
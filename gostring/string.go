@@ -4,17 +4,18 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"log"
 	"net"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/catinello/base62"
 	"github.com/google/uuid"
 	"github.com/sony/sonyflake"
+	"github.com/zhuomouren/gohelpers/goip"
 )
 
 type GoString struct{}
@@ -31,7 +32,7 @@ var flake = NewSonyflake()
 func NewSonyflake() *sonyflake.Sonyflake {
 	st := sonyflake.Settings{}
 	st.MachineID = func() (uint16, error) {
-		ip, err := privateIPv4()
+		ip, err := goip.Helper.PrivateIPv4()
 		if err != nil {
 			ip = net.IP([]byte{192, 168, 1, 1})
 		}
@@ -42,31 +43,6 @@ func NewSonyflake() *sonyflake.Sonyflake {
 	return sonyflake.NewSonyflake(st)
 }
 
-func privateIPv4() (net.IP, error) {
-	as, err := net.InterfaceAddrs()
-	if err != nil {
-		return nil, err
-	}
-
-	for _, a := range as {
-		ipnet, ok := a.(*net.IPNet)
-		if !ok || ipnet.IP.IsLoopback() {
-			continue
-		}
-
-		ip := ipnet.IP.To4()
-		if isPrivateIPv4(ip) {
-			return ip, nil
-		}
-	}
-	return nil, errors.New("no private ip address")
-}
-
-func isPrivateIPv4(ip net.IP) bool {
-	return ip != nil &&
-		(ip[0] == 10 || ip[0] == 172 && (ip[1] >= 16 && ip[1] < 32) || ip[0] == 192 && ip[1] == 168)
-}
-
 func (this *GoString) ShortUUID() string {
 	// flake := sonyflake.NewSonyflake(sonyflake.Settings{})
 	if flake == nil {
@@ -297,7 +273,7 @@ func (this *GoString) RegexpOneMatch(regex, data string) string {
 
 	regex = this.DeepProcessingRegex(regex)
 
-	re, err := regexp.Compile(`(?i)` + regex)
+	re, err := compileRegexp(`(?i)` + regex)
 	if err != nil {
 		return ""
 	}
@@ -310,9 +286,9 @@ func (this *GoString) RegexpOneMatch(regex, data string) string {
 	return ""
 }
 
-// 是否精确匹配
+// 是否精确匹配。需要匹配大量 URL 时使用 gonet.URLPattern
 func (this *GoString) IsExactMatch(regex, data string) bool {
-	re, err := regexp.Compile(`(?i)` + regex)
+	re, err := compileRegexp(`(?i)` + regex)
 	if err != nil {
 		return false
 	}
@@ -321,6 +297,38 @@ func (this *GoString) IsExactMatch(regex, data string) bool {
 	return strings.EqualFold(str, data)
 }
 
+// 编译过的正则，同一个规则只编译一次。
+// 适合规则数量固定的情况，缓存超过 regexpCacheSize 个时清空，避免规则一直变化时占用的内存不断增长
+const regexpCacheSize = 1024
+
+var (
+	regexpCache = make(map[string]*regexp.Regexp)
+	regexpLock  = &sync.RWMutex{}
+)
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	regexpLock.RLock()
+	re, ok := regexpCache[expr]
+	regexpLock.RUnlock()
+	if ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	regexpLock.Lock()
+	if len(regexpCache) >= regexpCacheSize {
+		regexpCache = make(map[string]*regexp.Regexp)
+	}
+	regexpCache[expr] = re
+	regexpLock.Unlock()
+
+	return re, nil
+}
+
 // 是否存在匹配
 func (this *GoString) IsMatch(regex, data string) bool {
 	if m, _ := regexp.MatchString(regex, data); !m {